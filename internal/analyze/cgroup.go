@@ -0,0 +1,113 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// preferCgroup swaps in the exact CPU and storage numbers of a run's cgroup.
+// Unlike rusage they include descendants nobody waited for, and io.stat
+// counts what reached the block layer for the whole tree.
+func preferCgroup(run model.RunResult) model.RunResult {
+	cg := run.Cgroup
+	if cg == nil {
+		return run
+	}
+	run.UserMS, run.SysMS = cg.CPUUserMS, cg.CPUSystemMS
+	if run.WallMS > 0 {
+		run.CPURatio = cg.CPUUsageMS / run.WallMS
+	}
+	if !cgroupMissing(cg, "io.stat") {
+		var io model.IOCounters
+		if run.IO != nil {
+			io = *run.IO
+		}
+		io.ReadBytes, io.WriteBytes = cg.IOReadBytes, cg.IOWriteBytes
+		run.IO = &io
+	}
+	return run
+}
+
+func cgroupMissing(cg *model.CgroupStats, file string) bool {
+	for _, f := range cg.Missing {
+		if f == file {
+			return true
+		}
+	}
+	return false
+}
+
+func cgroupNote(run model.RunResult) string {
+	cg := run.Cgroup
+	note := fmt.Sprintf("cgroup: cpu %.1fms (rusage %.1fms)", cg.CPUUsageMS, run.UserMS+run.SysMS)
+	if !cgroupMissing(cg, "memory.peak") {
+		note += fmt.Sprintf(" memory_peak=%.1fMB", mib(cg.MemoryPeakBytes))
+	}
+	if !cgroupMissing(cg, "pids.peak") {
+		note += fmt.Sprintf(" pids_peak=%d", cg.PidsPeak)
+	}
+	if cg.OOMKills > 0 {
+		note += fmt.Sprintf(" oom_kills=%d", cg.OOMKills)
+	}
+	return note + "; cgroup CPU and I/O numbers used over rusage"
+}
+
+// cpuThrottled fires when the enclosing cgroup's CFS quota stalled the run.
+// throttled_usec counts every throttled stretch of the whole group, which
+// includes our command but may include neighbours in the same container.
+func cpuThrottled(run model.RunResult) []model.Explanation {
+	l := run.Limits
+	if l == nil || l.ThrottledPeriods == 0 || run.WallMS <= 0 {
+		return nil
+	}
+	if l.ThrottledMS < 10 || l.ThrottledMS < run.WallMS*0.05 {
+		return nil
+	}
+	quota := "the CPU quota"
+	if l.CPUQuotaCores > 0 {
+		quota = fmt.Sprintf("the %.2g-core CPU quota", l.CPUQuotaCores)
+	}
+	return []model.Explanation{
+		{
+			ID:       "CPU_THROTTLED",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Throttled by %s for %.0fms (~%.0f%% of wall)", quota, l.ThrottledMS, ratio(l.ThrottledMS, run.WallMS)*100),
+			Details:  fmt.Sprintf("throttled_periods=%d periods=%d throttled_ms=%.1f cpu_quota_cores=%.2f", l.ThrottledPeriods, l.Periods, l.ThrottledMS, l.CPUQuotaCores),
+			Suggestions: []string{
+				"Raise the container's CPU limit (cpu.max, resources.limits.cpu) or drop it and keep only a request",
+				"Size thread pools and -j to the quota, not the host's core count (GOMAXPROCS, nproc)",
+				"Bursty parallel phases burn the quota early in each period; smoothing them out reduces stalls",
+			},
+		},
+	}
+}
+
+// memoryHighReclaim fires when the enclosing cgroup hit memory.high or
+// memory.max during the run, which means the kernel reclaimed (or swapped)
+// to stay under the limit.
+func memoryHighReclaim(run model.RunResult) []model.Explanation {
+	l := run.Limits
+	if l == nil || l.MemoryHighEvents+l.MemoryMaxEvents+l.OOMKills == 0 {
+		return nil
+	}
+	severity := "warn"
+	msg := fmt.Sprintf("Container memory limit reached %d times; the kernel reclaimed memory to stay under it", l.MemoryHighEvents+l.MemoryMaxEvents)
+	if l.OOMKills > 0 {
+		severity = "critical"
+		msg = fmt.Sprintf("Container memory limit reached; %d processes were OOM-killed", l.OOMKills)
+	}
+	return []model.Explanation{
+		{
+			ID:       "MEMORY_HIGH_RECLAIM",
+			Severity: severity,
+			Message:  msg,
+			Details:  fmt.Sprintf("memory_high_events=%d memory_max_events=%d oom_kills=%d memory_high=%.0fMB memory_max=%.0fMB", l.MemoryHighEvents, l.MemoryMaxEvents, l.OOMKills, mib(l.MemoryHighBytes), mib(l.MemoryMaxBytes)),
+			Suggestions: []string{
+				"Raise the container's memory limit, or memory.high if it is set below memory.max",
+				"Reclaim evicts page cache first, so file-heavy work re-reads from disk; watch for major faults",
+				"Lower parallelism so fewer workers hold memory at once",
+			},
+		},
+	}
+}
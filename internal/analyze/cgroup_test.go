@@ -0,0 +1,72 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestAnalyzePrefersCgroupNumbers(t *testing.T) {
+	// rusage saw only the shell; the cgroup saw the daemon it started too.
+	run := model.RunResult{
+		WallMS:   1000,
+		UserMS:   50,
+		CPURatio: 0.05,
+		IO:       &model.IOCounters{ReadBytes: 0},
+		Cgroup: &model.CgroupStats{
+			CPUUsageMS: 900, CPUUserMS: 850, CPUSystemMS: 50,
+			IOReadBytes: 2 << 30,
+			Missing:     []string{"pids.peak"},
+		},
+	}
+	analysis := AnalyzeRun(run)
+	if analysis.Classification != ClassificationCPU {
+		t.Fatalf("expected cgroup CPU time to drive classification, got %s", analysis.Classification)
+	}
+	var storage bool
+	for _, e := range analysis.Explanations {
+		if e.ID == "STORAGE_READ_HEAVY" {
+			storage = true
+		}
+	}
+	if !storage {
+		t.Fatalf("expected io.stat bytes to be used, got %+v", analysis.Explanations)
+	}
+
+	run.Cgroup.Missing = append(run.Cgroup.Missing, "io.stat")
+	if got := preferCgroup(run); got.IO.ReadBytes != 0 {
+		t.Fatalf("io.stat was missing, rusage-side bytes should stay: %+v", got.IO)
+	}
+}
+
+func TestCPUThrottledRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS: 2000,
+		Limits: &model.CgroupLimits{CPUQuotaCores: 1.5, Periods: 20, ThrottledPeriods: 12, ThrottledMS: 600},
+	}
+	expl := cpuThrottled(run)
+	if len(expl) == 0 || !strings.Contains(expl[0].Message, "1.5-core") {
+		t.Fatalf("expected CPU_THROTTLED naming the quota, got %+v", expl)
+	}
+	run.Limits.ThrottledMS = 20
+	if expl := cpuThrottled(run); len(expl) != 0 {
+		t.Fatalf("did not expect throttling at 1%% of wall: %+v", expl)
+	}
+}
+
+func TestMemoryHighReclaimRule(t *testing.T) {
+	run := model.RunResult{WallMS: 1000, Limits: &model.CgroupLimits{MemoryHighBytes: 512 << 20}}
+	if expl := memoryHighReclaim(run); len(expl) != 0 {
+		t.Fatalf("a limit alone is not a finding: %+v", expl)
+	}
+	run.Limits.MemoryHighEvents = 7
+	expl := memoryHighReclaim(run)
+	if len(expl) == 0 || expl[0].Severity != "warn" {
+		t.Fatalf("expected MEMORY_HIGH_RECLAIM, got %+v", expl)
+	}
+	run.Limits.OOMKills = 1
+	if expl := memoryHighReclaim(run); expl[0].Severity != "critical" {
+		t.Fatalf("expected OOM kills to be critical, got %+v", expl)
+	}
+}
@@ -11,6 +11,12 @@ const (
 	ClassificationMixed  = "MIXED"
 	ClassificationCPU    = "CPU_BOUND"
 	ClassificationParCPU = "PARALLEL_CPU"
+	// PARALLEL_CPU is split in two once the number of usable CPUs is known.
+	ClassificationParSaturated = "PARALLEL_SATURATED"
+	ClassificationParUnderused = "PARALLEL_UNDERUSED"
+	// ClassificationStarved replaces a waiting verdict when the wait was
+	// mostly spent runnable on a run queue rather than blocked.
+	ClassificationStarved = "CPU_STARVED"
 )
 
 func Classify(cpuRatio float64) string {
@@ -26,16 +32,70 @@ func Classify(cpuRatio float64) string {
 	}
 }
 
+// saturatedUtil is the per-core utilisation from which parallel work counts
+// as keeping every usable CPU busy.
+const saturatedUtil = 0.8
+
+// ClassifyCores refines Classify with the number of CPUs the command could
+// use: a ratio of 3.9 saturates a 4-CPU quota but barely uses a 32-core box.
+func ClassifyCores(cpuRatio, cpus float64) string {
+	class := Classify(cpuRatio)
+	if class != ClassificationParCPU || cpus <= 0 {
+		return class
+	}
+	if cpuRatio/cpus >= saturatedUtil {
+		return ClassificationParSaturated
+	}
+	return ClassificationParUnderused
+}
+
+// classifyRun uses the recorded CPU count when there is one; older records
+// fall back to the fixed thresholds.
+func classifyRun(run model.RunResult) string {
+	if run.CPUs != nil {
+		return ClassifyCores(run.CPURatio, run.CPUs.Available)
+	}
+	return Classify(run.CPURatio)
+}
+
 // analyze run builds simple heuristics.
 func AnalyzeRun(run model.RunResult) model.Analysis {
+	var notes []string
+	if run.Cgroup != nil {
+		notes = append(notes, cgroupNote(run))
+		run = preferCgroup(run)
+	}
 	analysis := model.Analysis{
-		Classification: Classify(run.CPURatio),
+		Classification: classifyRun(run),
+		Notes:          notes,
+	}
+	starved := cpuStarved(run)
+	if len(starved) > 0 && (analysis.Classification == ClassificationWaitIO || analysis.Classification == ClassificationMixed) {
+		analysis.Classification = ClassificationStarved
+		analysis.Notes = append(analysis.Notes, fmt.Sprintf("CPU_STARVED overrides cpu_ratio classification (run_delay_ms=%.1f)", run.RunDelayMS))
 	}
 
 	analysis.Explanations = append(analysis.Explanations, baseExplanation(run, analysis.Classification))
+	analysis.Explanations = append(analysis.Explanations, timedOut(run)...)
+	analysis.Explanations = append(analysis.Explanations, starved...)
+	analysis.Explanations = append(analysis.Explanations, cpuThrottled(run)...)
+	analysis.Explanations = append(analysis.Explanations, memoryHighReclaim(run)...)
+	analysis.Explanations = append(analysis.Explanations, systemIOPressure(run)...)
+	analysis.Explanations = append(analysis.Explanations, systemMemoryPressure(run)...)
+	analysis.Explanations = append(analysis.Explanations, memoryStalls(run, analysis.Classification)...)
+	analysis.Explanations = append(analysis.Explanations, serialBottleneck(run)...)
+	analysis.Explanations = append(analysis.Explanations, oversubscription(run)...)
+	// Traced findings name a concrete cause, so they rank ahead of the
+	// sampled wait split.
+	analysis.Explanations = append(analysis.Explanations, networkLatency(run)...)
+	analysis.Explanations = append(analysis.Explanations, syscallRules(run)...)
+	analysis.Explanations = append(analysis.Explanations, filesystemWalk(run)...)
+	analysis.Explanations = append(analysis.Explanations, terminalOutputBound(run)...)
 
-	ioExpl := ioWait(run)
-	analysis.Explanations = append(analysis.Explanations, ioExpl...)
+	if analysis.Classification != ClassificationStarved {
+		ioExpl := ioWait(run)
+		analysis.Explanations = append(analysis.Explanations, ioExpl...)
+	}
 
 	sysExpl := highSysTime(run)
 	if len(sysExpl) > 0 {
@@ -43,9 +103,40 @@ func AnalyzeRun(run model.RunResult) model.Analysis {
 	}
 	analysis.Explanations = append(analysis.Explanations, sysExpl...)
 
+	analysis.Explanations = append(analysis.Explanations, majorPageFaults(run)...)
+	analysis.Explanations = append(analysis.Explanations, blockIOHeavy(run)...)
+	analysis.Explanations = append(analysis.Explanations, contextSwitchHeavy(run)...)
+	analysis.Explanations = append(analysis.Explanations, idleStretches(run)...)
+	analysis.Explanations = append(analysis.Explanations, storageVolume(run)...)
+	analysis.Explanations = append(analysis.Explanations, leftoverProcesses(run)...)
+	analysis.Explanations = append(analysis.Explanations, noisyEnvironment(run)...)
+	analysis.Explanations = append(analysis.Explanations, firstRunPenalty(run)...)
+	if run.Interrupted {
+		analysis.Notes = append(analysis.Notes, fmt.Sprintf("interrupted by a signal after %d samples; an interrupted sample's timings are cut short, so it is left out of the stats", len(run.RawSamples)))
+	} else if r := run.Repeat; r != nil && r.Count < r.Requested {
+		analysis.Notes = append(analysis.Notes, fmt.Sprintf("--max-total-time %.1fs stopped the run after %d of %d samples", r.MaxTotalMS/1000, r.Count, r.Requested))
+	}
+	if note := idleNote(run); note != "" {
+		analysis.Notes = append(analysis.Notes, note)
+	}
+	if len(run.Syscalls) > 0 {
+		analysis.Notes = append(analysis.Notes, fmt.Sprintf("syscalls: %d calls traced, most time in %s", totalSyscalls(run.Syscalls), topSyscalls(run.Syscalls, 3)))
+	}
+	if run.Files != nil {
+		analysis.Notes = append(analysis.Notes, fmt.Sprintf("files: %d paths traced, %d opens %d stats %d readdirs", run.Files.Paths, run.Files.Opens, run.Files.Stats, run.Files.Readdirs))
+	}
+	if run.Perf != nil {
+		analysis.Notes = append(analysis.Notes, perfNote(run.Perf))
+	}
+	if run.IO != nil {
+		if note := ioNote(run); note != "" {
+			analysis.Notes = append(analysis.Notes, note)
+		}
+	}
+
 	memExpl := memoryPressure(run)
 	if len(memExpl) > 0 {
-		analysis.Notes = append(analysis.Notes, "MEMORY_PRESSURE triggered for single run")
+		analysis.Notes = append(analysis.Notes, fmt.Sprintf("%s triggered for single run", memExpl[0].ID))
 	}
 	analysis.Explanations = append(analysis.Explanations, memExpl...)
 
@@ -19,3 +19,21 @@ func TestClassifyThresholds(t *testing.T) {
 		}
 	}
 }
+
+func TestClassifyCores(t *testing.T) {
+	cases := []struct {
+		ratio, cpus float64
+		want        string
+	}{
+		{3.9, 4, ClassificationParSaturated},
+		{3.9, 32, ClassificationParUnderused},
+		{1.0, 32, ClassificationCPU},
+		{0.2, 32, ClassificationWaitIO},
+		{2.0, 0, ClassificationParCPU},
+	}
+	for _, tc := range cases {
+		if got := ClassifyCores(tc.ratio, tc.cpus); got != tc.want {
+			t.Fatalf("ratio %.2f on %.0f cpus => %s, want %s", tc.ratio, tc.cpus, got, tc.want)
+		}
+	}
+}
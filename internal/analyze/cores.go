@@ -0,0 +1,144 @@
+package analyze
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// coreUtil is the average share of the usable CPUs the command kept busy.
+func coreUtil(run model.RunResult) (float64, bool) {
+	if run.CPUs == nil || run.CPUs.Available <= 0 {
+		return 0, false
+	}
+	return run.CPURatio / run.CPUs.Available, true
+}
+
+// parallelByDefault are tools that spread work over all CPUs unless told not
+// to.
+var parallelByDefault = map[string]bool{
+	"ninja": true, "cargo": true, "bazel": true, "bazelisk": true,
+	"jest": true, "vitest": true, "nextest": true,
+}
+
+// parallelHint says why the command would be expected to use several cores,
+// or returns "" if nothing suggests it.
+func parallelHint(run model.RunResult) string {
+	var args []string
+	for _, a := range run.Command {
+		// sh -c "make -j8" arrives as one argument.
+		args = append(args, strings.Fields(a)...)
+	}
+	for i, a := range args {
+		if tool := filepath.Base(a); parallelByDefault[tool] {
+			return fmt.Sprintf("%s runs jobs in parallel by default", tool)
+		}
+		if filepath.Base(a) == "go" && i+1 < len(args) && (args[i+1] == "build" || args[i+1] == "test" || args[i+1] == "vet") {
+			return fmt.Sprintf("go %s runs packages in parallel", args[i+1])
+		}
+		switch {
+		case a == "--jobs" || a == "--parallel" || strings.HasPrefix(a, "--jobs=") || strings.HasPrefix(a, "--parallel="):
+			return fmt.Sprintf("%s asks for parallel jobs", a)
+		case a == "-j" && i+1 < len(args) && isNumber(args[i+1]):
+			return fmt.Sprintf("-j %s asks for parallel jobs", args[i+1])
+		case strings.HasPrefix(a, "-j") && isNumber(a[2:]):
+			return fmt.Sprintf("%s asks for parallel jobs", a)
+		}
+	}
+	if sample, ok := SlowestTimeline(run); ok {
+		if sum := SummarizeTimeline(sample.Timeline); sum.PeakProcs >= 3 {
+			return fmt.Sprintf("up to %d processes ran at once", sum.PeakProcs)
+		}
+	}
+	return ""
+}
+
+func isNumber(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// hotThread returns the thread that did most of a multi-threaded run's CPU
+// work, if one was on CPU for most of the wall time.
+func hotThread(run model.RunResult) (model.ThreadCPU, bool) {
+	th := run.Threads
+	if th == nil || th.Seen < 2 || len(th.Top) == 0 || run.WallMS <= 0 {
+		return model.ThreadCPU{}, false
+	}
+	hot := th.Top[0]
+	if hot.CPUMS < run.WallMS*0.8 || hot.CPUMS < th.CPUMS*0.6 {
+		return model.ThreadCPU{}, false
+	}
+	return hot, true
+}
+
+// serialBottleneck fires when one thread of a multi-threaded command was
+// pinned while the rest idled, or when a command that looks parallel kept
+// about one core busy, on a machine with more cores to give.
+func serialBottleneck(run model.RunResult) []model.Explanation {
+	if run.CPUs == nil || run.CPUs.Available < 2 {
+		return nil
+	}
+	if hot, ok := hotThread(run); ok {
+		th := run.Threads
+		return []model.Explanation{
+			{
+				ID:       "SERIAL_BOTTLENECK",
+				Severity: "warn",
+				Message:  fmt.Sprintf("One thread, %s (tid %d), was on CPU for %.0f%% of wall while %d others shared %.0fms", hot.Comm, hot.TID, ratio(hot.CPUMS, run.WallMS)*100, th.Seen-1, th.CPUMS-hot.CPUMS),
+				Details:  fmt.Sprintf("hot_thread=%s pid=%d tid=%d thread_cpu_ms=%.1f total_thread_cpu_ms=%.1f threads=%d cpus=%.3g", hot.Comm, hot.PID, hot.TID, hot.CPUMS, th.CPUMS, th.Seen, run.CPUs.Available),
+				Suggestions: []string{
+					"Profile that thread; a lock holder, event loop or main thread doing all the work is typical",
+					"Split its work across the worker pool, or move blocking and CPU-heavy steps off the event loop",
+					"More threads will not help until this one gets faster",
+				},
+			},
+		}
+	}
+	if run.CPURatio < 0.75 || run.CPURatio > 1.3 {
+		return nil
+	}
+	hint := parallelHint(run)
+	if hint == "" {
+		return nil
+	}
+	return []model.Explanation{
+		{
+			ID:       "SERIAL_BOTTLENECK",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Used about one core (cpu_ratio %.2f) of %.3g usable, although %s", run.CPURatio, run.CPUs.Available, hint),
+			Details:  fmt.Sprintf("cpu_ratio=%.2f cpus=%.3g limit=%s", run.CPURatio, run.CPUs.Available, run.CPUs.Limit),
+			Suggestions: []string{
+				"A single target or test may be gating the rest; check the dependency graph or the slowest test file",
+				"Check the job count is not pinned to 1 by config, an environment variable or the CPU count seen in a container",
+				"Re-run with --sample-interval to find the stretch where only one process is busy",
+			},
+		},
+	}
+}
+
+// oversubscription fires when many more threads wanted CPU than there were
+// cores, and they measurably queued for one.
+func oversubscription(run model.RunResult) []model.Explanation {
+	th := run.Threads
+	if th == nil || run.CPUs == nil || run.CPUs.Available <= 0 || run.WallMS <= 0 {
+		return nil
+	}
+	if th.Busy < 4 || float64(th.Busy) < run.CPUs.Available*2 || run.RunDelayMS < run.WallMS*0.10 {
+		return nil
+	}
+	return []model.Explanation{
+		{
+			ID:       "OVERSUBSCRIPTION",
+			Severity: "warn",
+			Message:  fmt.Sprintf("%d busy threads competed for %.3g usable CPUs and queued %.0fms for a core", th.Busy, run.CPUs.Available, run.RunDelayMS),
+			Details:  fmt.Sprintf("busy_threads=%d threads_seen=%d peak_threads=%d cpus=%.3g limit=%s run_delay_ms=%.1f involuntary_ctx_switches=%d", th.Busy, th.Seen, th.Peak, run.CPUs.Available, run.CPUs.Limit, run.RunDelayMS, run.InvolCtxSw),
+			Suggestions: []string{
+				"Size worker pools to the usable CPUs, not the host's core count; containers often report the host's",
+				"Nested parallelism (a parallel build running parallel tests, BLAS threads inside worker processes) multiplies thread counts",
+				"Set GOMAXPROCS, OMP_NUM_THREADS, -j or the pool size explicitly",
+			},
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestSerialBottleneckRule(t *testing.T) {
+	run := model.RunResult{
+		Command:  []string{"sh", "-c", "make -j16 all"},
+		WallMS:   10000,
+		CPURatio: 1.02,
+		CPUs:     &model.CPUCount{Online: 16, Available: 16, Limit: "online"},
+	}
+	expl := serialBottleneck(run)
+	if len(expl) == 0 || !strings.Contains(expl[0].Message, "-j16") {
+		t.Fatalf("expected SERIAL_BOTTLENECK citing -j16, got %+v", expl)
+	}
+
+	run.Command = []string{"gzip", "-9", "big.tar"}
+	if expl := serialBottleneck(run); len(expl) != 0 {
+		t.Fatalf("a serial tool should not be flagged: %+v", expl)
+	}
+	run.RawSamples = []model.Sample{{WallMS: 10000, Timeline: []model.TimelinePoint{{OffsetMS: 100, Procs: 1}, {OffsetMS: 200, Procs: 5}}}}
+	if expl := serialBottleneck(run); len(expl) == 0 {
+		t.Fatalf("expected concurrent processes in the timeline to count as parallel intent")
+	}
+
+	run.CPUs.Available = 1
+	if expl := serialBottleneck(run); len(expl) != 0 {
+		t.Fatalf("one usable CPU cannot be underused: %+v", expl)
+	}
+}
+
+func TestSerialBottleneckHotThread(t *testing.T) {
+	run := model.RunResult{
+		Command:  []string{"./server"},
+		WallMS:   1000,
+		CPURatio: 1.1,
+		CPUs:     &model.CPUCount{Online: 8, Available: 8, Limit: "online"},
+		Threads: &model.ThreadStats{Seen: 8, Peak: 8, Busy: 2, CPUMS: 1100, Top: []model.ThreadCPU{
+			{PID: 10, TID: 12, Comm: "event-loop", CPUMS: 950},
+			{PID: 10, TID: 13, Comm: "worker", CPUMS: 150},
+		}},
+	}
+	expl := serialBottleneck(run)
+	if len(expl) != 1 || !strings.Contains(expl[0].Message, "event-loop") {
+		t.Fatalf("expected SERIAL_BOTTLENECK naming the hot thread, got %+v", expl)
+	}
+
+	// Eight threads each a little busy is parallel work, not a bottleneck.
+	run.Threads.Top[0].CPUMS = 140
+	if expl := serialBottleneck(run); len(expl) != 0 {
+		t.Fatalf("unexpected SERIAL_BOTTLENECK: %+v", expl)
+	}
+}
+
+func TestOversubscriptionRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS:     1000,
+		RunDelayMS: 400,
+		CPUs:       &model.CPUCount{Online: 2, Available: 2, Limit: "online"},
+		Threads:    &model.ThreadStats{Seen: 40, Peak: 40, Busy: 16, CPUMS: 1900},
+	}
+	if expl := oversubscription(run); len(expl) != 1 || expl[0].ID != "OVERSUBSCRIPTION" {
+		t.Fatalf("expected OVERSUBSCRIPTION, got %+v", expl)
+	}
+	run.RunDelayMS = 10
+	if expl := oversubscription(run); len(expl) != 0 {
+		t.Fatalf("threads that never queued are not oversubscribed: %+v", expl)
+	}
+}
@@ -0,0 +1,104 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// poorQuality is the measurement quality below which a run's numbers say as
+// much about the machine as about the command.
+const poorQuality = 70
+
+func poorEnvironment(run model.RunResult) bool {
+	return run.Environment != nil && run.Environment.Quality < poorQuality
+}
+
+// noisyEnvironment reports a run measured on a busy or throttled machine. It
+// stays at info severity so it does not displace what the command itself did.
+func noisyEnvironment(run model.RunResult) []model.Explanation {
+	if !poorEnvironment(run) {
+		return nil
+	}
+	env := run.Environment
+	return []model.Explanation{
+		{
+			ID:       "NOISY_ENVIRONMENT",
+			Severity: "info",
+			Message:  fmt.Sprintf("Measurement quality %d/100: %s", env.Quality, strings.Join(env.Issues, "; ")),
+			Details:  environmentDetails(env),
+			Suggestions: []string{
+				"Re-measure on an idle machine, or with --repeat to see how much the numbers move",
+				"Close browsers, IDE indexers and builds, or pin CI jobs to dedicated runners",
+				"Set the CPU governor to performance for benchmarking (cpupower frequency-set -g performance)",
+			},
+		},
+	}
+}
+
+func environmentDetails(env *model.Environment) string {
+	details := fmt.Sprintf("quality=%d load_start=%.2f load_end=%.2f steal=%.1f%% iowait=%.1f%%", env.Quality, env.LoadStart, env.LoadEnd, env.StealPercent, env.IOWaitPercent)
+	if env.Governor != "" {
+		details += " governor=" + env.Governor
+	}
+	if env.FreqCapped {
+		details += " freq_capped"
+	}
+	for i, p := range env.BusyProcs {
+		if i >= 3 {
+			break
+		}
+		details += fmt.Sprintf(" busy=%s:%d:%.0f%%", p.Comm, p.PID, p.CPUPercent)
+	}
+	return details
+}
+
+// idleNote says when --wait-idle gave up, so a poor quality score is not a
+// surprise.
+func idleNote(run model.RunResult) string {
+	idle := run.Idle
+	if idle == nil || idle.TimedOut == 0 {
+		return ""
+	}
+	return fmt.Sprintf("wait-idle: %d waits gave up after %.0fs without the machine going quiet (load <= %.2f per CPU, pressure <= %.0f%%)", idle.TimedOut, idle.MaxWaitMS/1000, idle.MaxLoad, idle.MaxPressure)
+}
+
+// downrankNoisy demotes comparison findings to info when either run was
+// measured in a noisy environment, and leads with why.
+func downrankNoisy(a, b model.RunResult, explanations []model.Explanation) []model.Explanation {
+	if !poorEnvironment(a) && !poorEnvironment(b) {
+		return explanations
+	}
+	var sides []string
+	for _, r := range []struct {
+		name string
+		run  model.RunResult
+	}{{"A", a}, {"B", b}} {
+		if poorEnvironment(r.run) {
+			sides = append(sides, fmt.Sprintf("run %s %d/100 (%s)", r.name, r.run.Environment.Quality, strings.Join(r.run.Environment.Issues, "; ")))
+		}
+	}
+	for i := range explanations {
+		switch explanations[i].ID {
+		case "WALL_TIME_REGRESSION", "MEMORY_PRESSURE", "CPU_SHIFT":
+			explanations[i].Severity = "info"
+			explanations[i].Message += " (low measurement quality)"
+		}
+	}
+	noisy := model.Explanation{
+		ID:       "NOISY_ENVIRONMENT",
+		Severity: "warn",
+		Message:  "Differences may be noise: " + strings.Join(sides, ", "),
+		Suggestions: []string{
+			"Re-record both runs on a quiet machine with --repeat before trusting the deltas",
+			"Compare runs from the same host and time of day",
+		},
+	}
+	if poorEnvironment(b) {
+		noisy.Details = environmentDetails(b.Environment)
+	} else {
+		noisy.Details = environmentDetails(a.Environment)
+	}
+	return append(explanations[:1:1], append([]model.Explanation{noisy}, explanations[1:]...)...)
+}
@@ -0,0 +1,48 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestNoisyEnvironmentRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS:      100,
+		CPURatio:    0.9,
+		Environment: &model.Environment{Quality: 45, Issues: []string{"load 3.00 on 1 CPUs before the run"}},
+	}
+	expl := noisyEnvironment(run)
+	if len(expl) != 1 || expl[0].ID != "NOISY_ENVIRONMENT" {
+		t.Fatalf("expected NOISY_ENVIRONMENT, got %+v", expl)
+	}
+	run.Environment.Quality = 90
+	if expl := noisyEnvironment(run); len(expl) != 0 {
+		t.Fatalf("unexpected NOISY_ENVIRONMENT: %+v", expl)
+	}
+}
+
+func TestCompareDownranksNoisyRegression(t *testing.T) {
+	a := model.RunResult{ID: "a", WallMS: 100, CPURatio: 0.9, MaxRSSRaw: 1000, Environment: &model.Environment{Quality: 100}}
+	b := model.RunResult{ID: "b", WallMS: 200, CPURatio: 0.9, MaxRSSRaw: 1000, Environment: &model.Environment{Quality: 40}}
+
+	analysis := CompareAnalysis(a, b)
+	if analysis.Explanations[1].ID != "NOISY_ENVIRONMENT" || analysis.Explanations[1].Severity != "warn" {
+		t.Fatalf("expected NOISY_ENVIRONMENT to lead, got %+v", analysis.Explanations)
+	}
+	for _, e := range analysis.Explanations {
+		if e.ID == "WALL_TIME_REGRESSION" && e.Severity != "info" {
+			t.Fatalf("regression should be down-ranked: %+v", e)
+		}
+	}
+
+	b.Environment.Quality = 95
+	for _, e := range CompareAnalysis(a, b).Explanations {
+		if e.ID == "NOISY_ENVIRONMENT" {
+			t.Fatalf("quiet runs should not be flagged: %+v", e)
+		}
+		if e.ID == "WALL_TIME_REGRESSION" && e.Severity != "warn" {
+			t.Fatalf("regression should keep its severity: %+v", e)
+		}
+	}
+}
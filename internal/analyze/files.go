@@ -0,0 +1,131 @@
+package analyze
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// topDir is the directory a path is rolled up to: the first component below
+// cwd for paths inside it, otherwise the first two components (/usr/lib,
+// /home/alice), or the parent for shallower paths (/etc/hosts is /etc).
+func topDir(path, cwd string) string {
+	if cwd != "" {
+		if rel, err := filepath.Rel(cwd, path); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			if rel == "." {
+				return cwd
+			}
+			first, _, _ := strings.Cut(rel, "/")
+			return filepath.Join(cwd, first)
+		}
+	}
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	if len(parts) < 3 {
+		return filepath.Dir(path)
+	}
+	return "/" + parts[0] + "/" + parts[1]
+}
+
+// FileDirs rolls a run's traced paths up to top-level directories, most time
+// first.
+func FileDirs(run model.RunResult) []model.DirStat {
+	if run.Files == nil {
+		return nil
+	}
+	byDir := map[string]*model.DirStat{}
+	for _, fa := range run.Files.Top {
+		dir := topDir(fa.Path, run.CWD)
+		st := byDir[dir]
+		if st == nil {
+			st = &model.DirStat{Dir: dir}
+			byDir[dir] = st
+		}
+		st.Paths++
+		st.Opens += fa.Opens
+		st.Stats += fa.Stats
+		st.Readdirs += fa.Readdirs
+		st.Errors += fa.Errors
+		st.TimeMS += fa.TimeMS
+	}
+	out := make([]model.DirStat, 0, len(byDir))
+	for _, st := range byDir {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TimeMS != out[j].TimeMS {
+			return out[i].TimeMS > out[j].TimeMS
+		}
+		return out[i].Dir < out[j].Dir
+	})
+	return out
+}
+
+// filesystemWalk fires when stat and readdir calls account for most of the
+// command's sys time, which is what walking a big tree looks like.
+func filesystemWalk(run model.RunResult) []model.Explanation {
+	files := run.Files
+	if files == nil || run.WallMS <= 0 {
+		return nil
+	}
+	meta := files.Stats + files.Readdirs
+	if meta < 1000 || run.SysMS < run.WallMS*0.10 || files.MetaMS < run.SysMS*0.5 {
+		return nil
+	}
+
+	target := "the filesystem"
+	dirs := FileDirs(run)
+	var top []string
+	for i, d := range dirs {
+		if i >= 3 {
+			break
+		}
+		top = append(top, fmt.Sprintf("%s:%d", d.Dir, d.Stats+d.Readdirs))
+	}
+	if len(dirs) > 0 {
+		target = dirs[0].Dir
+	}
+	return []model.Explanation{
+		{
+			ID:       "FILESYSTEM_WALK",
+			Severity: "warn",
+			Message:  fmt.Sprintf("%d stat/readdir calls across %d paths, mostly under %s", meta, files.Paths, target),
+			Details:  fmt.Sprintf("stats=%d readdirs=%d meta_ms=%.1f sys_ms=%.1f errors=%d top=%s", files.Stats, files.Readdirs, files.MetaMS, run.SysMS, files.Errors, strings.Join(top, ",")),
+			Suggestions: []string{
+				"Exclude build outputs, vendored dependencies and VCS directories from globs and watchers",
+				"Narrow the root the tool scans, or point it at an explicit file list",
+				"Enable the tool's cache so unchanged trees are not rescanned",
+			},
+		},
+	}
+}
+
+// DiffFileSets compares the paths two traced runs touched. It returns nil
+// unless both runs were traced with --trace-files.
+func DiffFileSets(a, b model.RunResult) *model.FileSetDiff {
+	if a.Files == nil || b.Files == nil {
+		return nil
+	}
+	inA := make(map[string]bool, len(a.Files.Top))
+	for _, fa := range a.Files.Top {
+		inA[fa.Path] = true
+	}
+	diff := &model.FileSetDiff{}
+	inB := make(map[string]bool, len(b.Files.Top))
+	for _, fb := range b.Files.Top {
+		inB[fb.Path] = true
+		if inA[fb.Path] {
+			diff.Common++
+		} else {
+			diff.OnlyB = append(diff.OnlyB, fb)
+		}
+	}
+	for _, fa := range a.Files.Top {
+		if !inB[fa.Path] {
+			diff.OnlyA = append(diff.OnlyA, fa)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,67 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestTopDir(t *testing.T) {
+	cases := []struct{ path, want string }{
+		{"/src/app/node_modules/left-pad/index.js", "/src/app/node_modules"},
+		{"/src/app/package.json", "/src/app/package.json"},
+		{"/src/app", "/src/app"},
+		{"/usr/lib/python3/os.py", "/usr/lib"},
+		{"/etc/hosts", "/etc"},
+		{"/src/application/x", "/src/application"},
+	}
+	for _, c := range cases {
+		if got := topDir(c.path, "/src/app"); got != c.want {
+			t.Errorf("topDir(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+func TestFilesystemWalkRule(t *testing.T) {
+	run := model.RunResult{
+		CWD:    "/src/app",
+		WallMS: 2000,
+		SysMS:  900,
+		Files: &model.FileTrace{
+			Paths: 3, Stats: 4000, Readdirs: 800, MetaMS: 700,
+			Top: []model.FileAccess{
+				{Path: "/src/app/node_modules/a", Stats: 3000, Readdirs: 600, TimeMS: 500},
+				{Path: "/src/app/node_modules/b", Stats: 900, Readdirs: 200, TimeMS: 180},
+				{Path: "/src/app/src/main.js", Opens: 1, Stats: 100, TimeMS: 20},
+			},
+		},
+	}
+	dirs := FileDirs(run)
+	if len(dirs) != 2 || dirs[0].Dir != "/src/app/node_modules" || dirs[0].Paths != 2 || dirs[0].Readdirs != 800 {
+		t.Fatalf("unexpected directory rollup: %+v", dirs)
+	}
+	expl := filesystemWalk(run)
+	if len(expl) == 0 {
+		t.Fatalf("expected filesystem walk explanation")
+	}
+	if !strings.Contains(expl[0].Message, "/src/app/node_modules") {
+		t.Fatalf("expected the busiest directory to be named, got %q", expl[0].Message)
+	}
+	run.Files.MetaMS = 100
+	if expl := filesystemWalk(run); len(expl) != 0 {
+		t.Fatalf("did not expect filesystem walk when metadata is a small part of sys time: %+v", expl)
+	}
+}
+
+func TestDiffFileSets(t *testing.T) {
+	a := model.RunResult{Files: &model.FileTrace{Top: []model.FileAccess{{Path: "/x"}, {Path: "/y"}}}}
+	b := model.RunResult{Files: &model.FileTrace{Top: []model.FileAccess{{Path: "/y"}, {Path: "/z"}}}}
+	diff := DiffFileSets(a, b)
+	if diff == nil || diff.Common != 1 || len(diff.OnlyA) != 1 || diff.OnlyA[0].Path != "/x" || len(diff.OnlyB) != 1 || diff.OnlyB[0].Path != "/z" {
+		t.Fatalf("unexpected diff: %+v", diff)
+	}
+	if DiffFileSets(a, model.RunResult{}) != nil {
+		t.Fatalf("expected no diff when one run was not traced")
+	}
+}
@@ -0,0 +1,83 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+const (
+	// compositionThresholdKB matches memoryThreshold on Linux.
+	compositionThresholdKB = 512 * 1024
+	swapThresholdKB        = 64 * 1024
+)
+
+// memoryComposition splits MEMORY_PRESSURE by what the memory was, using the
+// sampled smaps_rollup totals. ok is false when the run has none, so the
+// caller falls back to max RSS.
+func memoryComposition(run model.RunResult) (out []model.Explanation, ok bool) {
+	m := run.Memory
+	if m == nil {
+		return nil, false
+	}
+	kb := func(n int64) float64 { return float64(n) / 1024 }
+	details := fmt.Sprintf("peak_mb=%.1f anon_mb=%.1f file_mb=%.1f shmem_mb=%.1f swap_mb=%.1f at_ms=%.0f", kb(m.PeakKB), kb(m.AnonKB), kb(m.FileKB), kb(m.ShmemKB), kb(m.SwapKB), m.AtMS)
+
+	if m.SwapKB >= swapThresholdKB {
+		out = append(out, model.Explanation{
+			ID:       "SWAPPING",
+			Severity: "warn",
+			Message:  fmt.Sprintf("%.0f MB of the process tree was swapped out", kb(m.SwapKB)),
+			Details:  details,
+			Suggestions: []string{
+				"Swapped pages fault back in at disk speed; free memory on the machine or raise the container's limit",
+				"Reduce the working set or parallelism so it fits in RAM",
+				"Check major faults and SYSTEM_MEMORY_PRESSURE to see how much time went to swap-in",
+			},
+		})
+	}
+	if m.PeakKB <= compositionThresholdKB {
+		return out, true
+	}
+
+	// One kind has to make up 60% of the peak to be named as the cause.
+	mapped := m.FileKB + m.ShmemKB
+	switch {
+	case m.AnonKB*5 >= m.PeakKB*3:
+		out = append(out, model.Explanation{
+			ID:       "ANON_HEAP_GROWTH",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Heap and other anonymous memory reached %.0f MB (%.0f%% of the %.0f MB peak)", kb(m.AnonKB), ratio(float64(m.AnonKB), float64(m.PeakKB))*100, kb(m.PeakKB)),
+			Details:  details,
+			Suggestions: []string{
+				"Profile allocations at the peak (pprof heap, heaptrack, memray) to see what holds the memory",
+				"Stream or batch input instead of loading it whole; bound caches and queues",
+				"Lower concurrency if every worker holds its own copy of the data",
+			},
+		})
+	case mapped*5 >= m.PeakKB*3:
+		out = append(out, model.Explanation{
+			ID:       "LARGE_FILE_MAPPINGS",
+			Severity: "warn",
+			Message:  fmt.Sprintf("%.0f MB of the %.0f MB peak is mapped files and shared memory, not heap", kb(mapped), kb(m.PeakKB)),
+			Details:  details,
+			Suggestions: []string{
+				"File-backed pages are cache the kernel can drop and re-read; this is not a leak",
+				"Find the large mappings with pmap -x or /proc/<pid>/smaps",
+				"Map only the ranges needed, or load the file once and share it across workers",
+			},
+		})
+	default:
+		out = append(out, model.Explanation{
+			ID:       "MEMORY_PRESSURE",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Memory usage peaked at %.0f MB, split between heap and mapped files", kb(m.PeakKB)),
+			Details:  details,
+			Suggestions: []string{
+				"Profile allocations for the heap share and check pmap -x for the mapped share",
+				"Reduce concurrency if each worker holds its own data",
+			},
+		})
+	}
+	return out, true
+}
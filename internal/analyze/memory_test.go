@@ -0,0 +1,37 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestMemoryCompositionRules(t *testing.T) {
+	ids := func(expl []model.Explanation) []string {
+		var out []string
+		for _, e := range expl {
+			out = append(out, e.ID)
+		}
+		return out
+	}
+	cases := []struct {
+		name string
+		mem  model.MemoryComposition
+		want []string
+	}{
+		{"heap", model.MemoryComposition{PeakKB: 2 << 20, AnonKB: 1800 << 10, FileKB: 248 << 10}, []string{"ANON_HEAP_GROWTH"}},
+		{"mmapped model", model.MemoryComposition{PeakKB: 2 << 20, AnonKB: 200 << 10, FileKB: 1800 << 10}, []string{"LARGE_FILE_MAPPINGS"}},
+		{"mixed", model.MemoryComposition{PeakKB: 1 << 20, AnonKB: 480 << 10, FileKB: 400 << 10, ShmemKB: 144 << 10}, []string{"MEMORY_PRESSURE"}},
+		{"swap only", model.MemoryComposition{PeakKB: 100 << 10, AnonKB: 100 << 10, SwapKB: 300 << 10}, []string{"SWAPPING"}},
+		{"small", model.MemoryComposition{PeakKB: 100 << 10, AnonKB: 100 << 10}, nil},
+	}
+	for _, tc := range cases {
+		mem := tc.mem
+		// A huge max RSS must not matter once the composition is known.
+		run := model.RunResult{MaxRSSRaw: 1 << 40, Memory: &mem}
+		got := ids(memoryPressure(run))
+		if len(got) != len(tc.want) || (len(got) > 0 && got[0] != tc.want[0]) {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
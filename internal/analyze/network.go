@@ -0,0 +1,51 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func netTime(d model.NetDest) float64 {
+	return d.ConnectMS + d.RecvBlockedMS
+}
+
+// networkLatency names the endpoint the command waited on longest. Waits on
+// parallel connections overlap, so the total can exceed wall time.
+func networkLatency(run model.RunResult) []model.Explanation {
+	if len(run.Network) == 0 || run.WallMS <= 0 {
+		return nil
+	}
+	slowest := run.Network[0]
+	for _, d := range run.Network[1:] {
+		if netTime(d) > netTime(slowest) {
+			slowest = d
+		}
+	}
+	waitMS := netTime(slowest)
+	if waitMS < 50 || waitMS < run.WallMS*0.20 {
+		return nil
+	}
+
+	var top []string
+	for i, d := range run.Network {
+		if i >= 3 {
+			break
+		}
+		top = append(top, fmt.Sprintf("%s:connect=%.1fms/recv=%.1fms", d.Address, d.ConnectMS, d.RecvBlockedMS))
+	}
+	return []model.Explanation{
+		{
+			ID:       "NETWORK_LATENCY",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Waited %.0fms on %s (~%.0f%% of wall)", waitMS, slowest.Address, ratio(waitMS, run.WallMS)*100),
+			Details:  fmt.Sprintf("connects=%d connect_errors=%d max_connect_ms=%.1f reads=%d top=%s", slowest.Connects, slowest.ConnectErrors, slowest.MaxConnectMS, slowest.Reads, strings.Join(top, ",")),
+			Suggestions: []string{
+				"Slow connects point at DNS, routing, or an overloaded listener; slow receives at the server's response time",
+				"Cache or mirror remote dependencies (package registries, artifact stores) close to where this runs",
+				"Reuse connections and issue independent requests in parallel",
+			},
+		},
+	}
+}
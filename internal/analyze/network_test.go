@@ -0,0 +1,29 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestNetworkLatencyRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS: 1000,
+		Network: []model.NetDest{
+			{Address: "10.0.0.5:5432", Proto: "tcp", Connects: 1, ConnectMS: 2, RecvBlockedMS: 80},
+			{Address: "151.101.0.223:443", Proto: "tcp", Connects: 3, ConnectMS: 150, RecvBlockedMS: 420},
+		},
+	}
+	expl := networkLatency(run)
+	if len(expl) == 0 {
+		t.Fatalf("expected network latency explanation")
+	}
+	if !strings.Contains(expl[0].Message, "151.101.0.223:443") {
+		t.Fatalf("expected the slowest endpoint to be named, got %q", expl[0].Message)
+	}
+	run.WallMS = 10000
+	if expl := networkLatency(run); len(expl) != 0 {
+		t.Fatalf("did not expect network latency at ~6%% of wall: %+v", expl)
+	}
+}
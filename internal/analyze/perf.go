@@ -0,0 +1,87 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// lowIPC is the instructions-per-cycle below which a CPU-bound run is mostly
+// stalled, usually on cache misses. Modern cores retire 2-4 per cycle on
+// compute-heavy code.
+const lowIPC = 1.0
+
+func cpuBusy(classification string) bool {
+	switch classification {
+	case ClassificationCPU, ClassificationParCPU, ClassificationParSaturated, ClassificationParUnderused:
+		return true
+	}
+	return false
+}
+
+// ipc returns the run's instructions per cycle, if hardware counters were
+// available.
+func ipc(run model.RunResult) (float64, bool) {
+	if run.Perf == nil || run.Perf.IPC <= 0 {
+		return 0, false
+	}
+	return run.Perf.IPC, true
+}
+
+// cpuSuggestions replaces the generic baseline advice for CPU-bound runs once
+// IPC says whether the cores are computing or waiting on memory.
+func cpuSuggestions(v float64) []string {
+	if v < lowIPC {
+		return []string{
+			fmt.Sprintf("IPC %.2f: the CPU time is mostly stalls on memory, not computation", v),
+			"Improve data locality: contiguous arrays, smaller structs, fewer pointer chases",
+			"Shrink the working set or process it in cache-sized blocks",
+		}
+	}
+	return []string{
+		fmt.Sprintf("IPC %.2f: the cores are busy executing, so the time is in the work itself", v),
+		"Profile hot functions (perf record, pprof, py-spy) and cut or cache the work",
+		"Spread independent work across cores",
+	}
+}
+
+// memoryStalls fires on a CPU-bound run whose hardware counters show few
+// instructions per cycle.
+func memoryStalls(run model.RunResult, classification string) []model.Explanation {
+	v, ok := ipc(run)
+	if !ok || !cpuBusy(classification) || v >= lowIPC {
+		return nil
+	}
+	p := run.Perf
+	details := fmt.Sprintf("ipc=%.2f cycles=%d instructions=%d", v, p.Cycles, p.Instructions)
+	if p.CacheMisses > 0 {
+		details += fmt.Sprintf(" cache_misses=%d misses_per_1k_instructions=%.1f", p.CacheMisses, float64(p.CacheMisses)*1000/float64(p.Instructions))
+	}
+	return []model.Explanation{
+		{
+			ID:       "MEMORY_STALLS",
+			Severity: "warn",
+			Message:  fmt.Sprintf("CPU-bound but only %.2f instructions per cycle: waiting on memory", v),
+			Details:  details,
+			Suggestions: []string{
+				"Improve data locality: contiguous arrays, smaller structs, fewer pointer chases",
+				"Shrink the working set or process it in cache-sized blocks",
+				"Find the hot loads with perf record -e cache-misses",
+				"Check for false sharing between threads writing neighbouring fields",
+			},
+		},
+	}
+}
+
+func perfNote(p *model.PerfCounters) string {
+	note := fmt.Sprintf("perf: task_clock=%.1fms context_switches=%d cpu_migrations=%d page_faults=%d", p.TaskClockMS, p.ContextSwitches, p.CPUMigrations, p.PageFaults)
+	if p.IPC > 0 {
+		note += fmt.Sprintf(" ipc=%.2f", p.IPC)
+	} else if len(p.Unavailable) > 0 {
+		note += " (hardware counters unavailable)"
+	}
+	if p.UserOnly {
+		note += " user-space only"
+	}
+	return note
+}
@@ -0,0 +1,43 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestMemoryStallsRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS:   1000,
+		UserMS:   950,
+		CPURatio: 0.95,
+		Perf:     &model.PerfCounters{TaskClockMS: 950, Cycles: 3_000_000_000, Instructions: 1_500_000_000, CacheMisses: 40_000_000, IPC: 0.5},
+	}
+	analysis := AnalyzeRun(run)
+	var found bool
+	for _, e := range analysis.Explanations {
+		if e.ID == "MEMORY_STALLS" {
+			found = true
+		}
+		if e.ID == "BASELINE" && !strings.Contains(e.Suggestions[0], "stalls on memory") {
+			t.Fatalf("expected memory-oriented baseline suggestions, got %v", e.Suggestions)
+		}
+	}
+	if !found {
+		t.Fatalf("expected MEMORY_STALLS, got %+v", analysis.Explanations)
+	}
+
+	run.Perf.IPC = 2.5
+	if expl := memoryStalls(run, ClassificationCPU); len(expl) != 0 {
+		t.Fatalf("did not expect memory stalls at IPC 2.5: %+v", expl)
+	}
+	if base := baseExplanation(run, ClassificationCPU); !strings.Contains(base.Suggestions[0], "busy executing") {
+		t.Fatalf("expected compute-oriented suggestions, got %v", base.Suggestions)
+	}
+
+	run.Perf.IPC = 0.5
+	if expl := memoryStalls(run, ClassificationWaitIO); len(expl) != 0 {
+		t.Fatalf("did not expect memory stalls on a waiting run: %+v", expl)
+	}
+}
@@ -0,0 +1,68 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func pressureDetails(p *model.Pressure) string {
+	return fmt.Sprintf("cpu_some_ms=%.1f memory_some_ms=%.1f memory_full_ms=%.1f io_some_ms=%.1f io_full_ms=%.1f", p.CPUSomeMS, p.MemorySomeMS, p.MemoryFullMS, p.IOSomeMS, p.IOFullMS)
+}
+
+// systemIOPressure fires when the machine spent much longer stalled on I/O
+// than the command's own disk waits explain, i.e. something else was
+// keeping the devices busy.
+func systemIOPressure(run model.RunResult) []model.Explanation {
+	p := run.Pressure
+	if p == nil || run.WallMS <= 0 {
+		return nil
+	}
+	own := splitWait(run).DiskMS
+	other := p.IOSomeMS - own
+	if other < 50 || other < run.WallMS*0.10 {
+		return nil
+	}
+	return []model.Explanation{
+		{
+			ID:       "SYSTEM_IO_PRESSURE",
+			Severity: "warn",
+			Message:  fmt.Sprintf("The system was stalled on I/O for %.0fms (~%.0f%% of wall) beyond the command's own disk waits", other, ratio(other, run.WallMS)*100),
+			Details:  fmt.Sprintf("%s own_disk_ms=%.1f", pressureDetails(p), own),
+			Suggestions: []string{
+				"Other processes were competing for storage; the slowness may not be the command's fault",
+				"Check iotop or pidstat -d for what else was reading or writing, and re-measure when it is quiet",
+				"On shared CI runners, move I/O-heavy jobs to dedicated disks or tmpfs",
+			},
+		},
+	}
+}
+
+// systemMemoryPressure fires when tasks on the machine waited on memory
+// reclaim, swap-in or thrashing for a noticeable part of the run.
+func systemMemoryPressure(run model.RunResult) []model.Explanation {
+	p := run.Pressure
+	if p == nil || run.WallMS <= 0 {
+		return nil
+	}
+	if p.MemorySomeMS < 20 || p.MemorySomeMS < run.WallMS*0.05 {
+		return nil
+	}
+	msg := fmt.Sprintf("The system was stalled on memory for %.0fms (~%.0f%% of wall)", p.MemorySomeMS, ratio(p.MemorySomeMS, run.WallMS)*100)
+	if p.MemoryFullMS >= run.WallMS*0.05 {
+		msg += fmt.Sprintf(", with every task blocked for %.0fms", p.MemoryFullMS)
+	}
+	return []model.Explanation{
+		{
+			ID:       "SYSTEM_MEMORY_PRESSURE",
+			Severity: "warn",
+			Message:  msg,
+			Details:  pressureDetails(p),
+			Suggestions: []string{
+				"The machine was short on memory and reclaiming or swapping; the slowness may not be the command's fault",
+				"Check free -m and the largest processes, and re-measure with more memory free",
+				"If the command itself is the largest user, reduce its working set or parallelism",
+			},
+		},
+	}
+}
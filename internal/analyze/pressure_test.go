@@ -0,0 +1,50 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestSystemIOPressureRule(t *testing.T) {
+	run := model.RunResult{
+		Platform: "linux/amd64",
+		WallMS:   1000,
+		UserMS:   100,
+		CPURatio: 0.1,
+		WaitStates: &model.WaitStates{
+			BlkioDelayMS: 200,
+		},
+		Pressure: &model.Pressure{IOSomeMS: 700},
+	}
+	expl := systemIOPressure(run)
+	if len(expl) != 1 || expl[0].ID != "SYSTEM_IO_PRESSURE" {
+		t.Fatalf("expected SYSTEM_IO_PRESSURE, got %+v", expl)
+	}
+
+	// The command's own disk waits account for the stall.
+	run.WaitStates.BlkioDelayMS = 680
+	if expl := systemIOPressure(run); len(expl) != 0 {
+		t.Fatalf("own I/O should not count as system pressure: %+v", expl)
+	}
+}
+
+func TestSystemMemoryPressureRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS:   1000,
+		Pressure: &model.Pressure{MemorySomeMS: 150, MemoryFullMS: 80},
+	}
+	expl := systemMemoryPressure(run)
+	if len(expl) != 1 || expl[0].ID != "SYSTEM_MEMORY_PRESSURE" {
+		t.Fatalf("expected SYSTEM_MEMORY_PRESSURE, got %+v", expl)
+	}
+
+	run.Pressure.MemorySomeMS = 10
+	if expl := systemMemoryPressure(run); len(expl) != 0 {
+		t.Fatalf("unexpected memory pressure: %+v", expl)
+	}
+	run.Pressure = nil
+	if expl := systemMemoryPressure(run); len(expl) != 0 {
+		t.Fatalf("no PSI should mean no explanation: %+v", expl)
+	}
+}
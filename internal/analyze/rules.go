@@ -3,6 +3,7 @@ package analyze
 import (
 	"fmt"
 	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/barthollomew/why-is-this-slow/internal/model"
@@ -20,17 +21,46 @@ func baseExplanation(run model.RunResult, classification string) model.Explanati
 		msg = "Mostly CPU-bound"
 	case ClassificationParCPU:
 		msg = "CPU time exceeds wall (parallel or multi-process)"
+	case ClassificationParSaturated:
+		msg = fmt.Sprintf("Parallel and keeping all %.3g usable CPUs busy", run.CPUs.Available)
+	case ClassificationParUnderused:
+		msg = fmt.Sprintf("Parallel, but only %.1f of %.3g usable CPUs busy on average", run.CPURatio, run.CPUs.Available)
+	case ClassificationStarved:
+		msg = "Runnable but waiting for a CPU core"
+	}
+
+	if util, ok := coreUtil(run); ok {
+		details += fmt.Sprintf(" cpus=%.3g limit=%s per_core=%.0f%%", run.CPUs.Available, run.CPUs.Limit, util*100)
+	}
+	suggestions := []string{
+		"Check if the command is expected to wait on I/O or locks",
+		"Trim unnecessary work or add tracing if unsure",
+	}
+	switch classification {
+	case ClassificationParSaturated:
+		suggestions = []string{
+			"Every usable CPU is busy: only more cores or less work will make this faster",
+			"If the CPU limit comes from a quota or affinity mask, raising it is the cheapest fix",
+		}
+	case ClassificationParUnderused:
+		suggestions = []string{
+			"Raise the job count (-j, --jobs, worker pool size) to match the usable CPUs",
+			"Look for serial phases (linking, one slow test file) with --sample-interval",
+		}
+	}
+	if v, ok := ipc(run); ok {
+		details += fmt.Sprintf(" ipc=%.2f", v)
+		if cpuBusy(classification) {
+			suggestions = cpuSuggestions(v)
+		}
 	}
 
 	return model.Explanation{
-		ID:       "BASELINE",
-		Severity: "info",
-		Message:  msg,
-		Details:  details,
-		Suggestions: []string{
-			"Check if the command is expected to wait on I/O or locks",
-			"Trim unnecessary work or add tracing if unsure",
-		},
+		ID:          "BASELINE",
+		Severity:    "info",
+		Message:     msg,
+		Details:     details,
+		Suggestions: suggestions,
 	}
 }
 
@@ -52,12 +82,15 @@ func highSysTime(run model.RunResult) []model.Explanation {
 			Details:  fmt.Sprintf("sys_ms=%.1f wall_ms=%.1f", run.SysMS, wall),
 			Suggestions: []string{
 				"Inspect disk or network I/O, or frequent context switches",
-				"Use strace/dtruss to see syscalls if precision is needed",
+				"Re-run with --syscalls (Linux) or use strace/dtruss to see which syscalls",
 			},
 		},
 	}
 }
 
+// ioWait explains time spent off-CPU. When the sampler saw the command's
+// thread states it splits the wait into disk and sleep/poll; without that data
+// it falls back to a single generic HIGH_IO_WAIT.
 func ioWait(run model.RunResult) []model.Explanation {
 	if run.WallMS <= 0 {
 		return nil
@@ -74,21 +107,329 @@ func ioWait(run model.RunResult) []model.Explanation {
 		return nil
 	}
 
+	split := splitWait(run)
+	if split.UnknownMS >= waitMS/2 {
+		return []model.Explanation{
+			{
+				ID:       "HIGH_IO_WAIT",
+				Severity: "warn",
+				Message:  fmt.Sprintf("High wait time (~%.0f%% of wall)", waitRatio*100),
+				Details:  fmt.Sprintf("wait_ms=%.1f wall_ms=%.1f cpu_ratio=%.2f", waitMS, run.WallMS, run.CPURatio),
+				Suggestions: []string{
+					"Check for disk/network latency, lock contention, or sleeps",
+					"Re-run with --syscalls (Linux) or strace/dtruss if the wait is unexpected",
+				},
+			},
+		}
+	}
+
+	details := fmt.Sprintf("wait_ms=%.1f disk_ms=%.1f sleep_ms=%.1f unknown_ms=%.1f", waitMS, split.DiskMS, split.SleepMS, split.UnknownMS)
+	if top := topWchan(run.WaitStates, 3); top != "" {
+		details += " wchan=" + top
+	}
+
+	var out []model.Explanation
+	if split.DiskMS >= waitMS/4 {
+		out = append(out, model.Explanation{
+			ID:       "DISK_WAIT",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Blocked on disk for ~%.0f%% of wall", ratio(split.DiskMS, run.WallMS)*100),
+			Details:  details,
+			Suggestions: []string{
+				"Find the files being read or written; a cold page cache or slow volume is typical",
+				"Check block I/O counts and major faults to see whether it is reads, writes, or paging",
+				"Move hot data to faster storage or avoid re-reading it",
+			},
+		})
+	}
+	if split.SleepMS >= waitMS/4 {
+		out = append(out, model.Explanation{
+			ID:       "SLEEP_OR_POLL",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Sleeping or polling for ~%.0f%% of wall", ratio(split.SleepMS, run.WallMS)*100),
+			Details:  details,
+			Suggestions: []string{
+				"Look for network calls, lock waits, child processes, or explicit sleeps/timeouts",
+				"The wchan names show where threads were parked (poll, futex, nanosleep)",
+			},
+		})
+	}
+	return out
+}
+
+// waitSplit is wait time broken down by what the sampler saw.
+type waitSplit struct {
+	DiskMS    float64
+	SleepMS   float64
+	UnknownMS float64
+}
+
+// splitWait divides wait time not already explained by run-queue delay. The
+// kernel's block I/O delay is exact when delay accounting is on; otherwise
+// the share of polls spent in D versus S state is used as an estimate.
+func splitWait(run model.RunResult) waitSplit {
+	waitMS, _ := waitStats(run)
+	waitMS = max(waitMS-run.RunDelayMS, 0)
+	ws := run.WaitStates
+	if ws == nil {
+		return waitSplit{UnknownMS: waitMS}
+	}
+
+	if ws.BlkioDelayMS > 0 {
+		disk := min(ws.BlkioDelayMS, waitMS)
+		if ws.SleepPolls > 0 {
+			return waitSplit{DiskMS: disk, SleepMS: waitMS - disk}
+		}
+		return waitSplit{DiskMS: disk, UnknownMS: waitMS - disk}
+	}
+
+	polls := ws.DiskPolls + ws.SleepPolls
+	if polls == 0 {
+		return waitSplit{UnknownMS: waitMS}
+	}
+	disk := waitMS * float64(ws.DiskPolls) / float64(polls)
+	return waitSplit{DiskMS: disk, SleepMS: waitMS - disk}
+}
+
+func topWchan(ws *model.WaitStates, n int) string {
+	if ws == nil || len(ws.Wchan) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(ws.Wchan))
+	for w := range ws.Wchan {
+		names = append(names, w)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if ws.Wchan[names[i]] != ws.Wchan[names[j]] {
+			return ws.Wchan[names[i]] > ws.Wchan[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+	parts := make([]string, 0, len(names))
+	for _, w := range names {
+		parts = append(parts, fmt.Sprintf("%s:%d", w, ws.Wchan[w]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// cpuStarved fires when run-queue delay explains a large share of the time
+// the command was not on a CPU. Delay is summed over threads, so it is also
+// checked against wall time for parallel commands with no wait left over.
+func cpuStarved(run model.RunResult) []model.Explanation {
+	const minDelayMS = 50.0
+	if run.RunDelayMS < minDelayMS || run.WallMS <= 0 {
+		return nil
+	}
+	waitMS, _ := waitStats(run)
+	waitShare := ratio(run.RunDelayMS, waitMS)
+	wallShare := ratio(run.RunDelayMS, run.WallMS)
+	if waitShare < 0.5 && wallShare < 0.25 {
+		return nil
+	}
+
+	return []model.Explanation{
+		{
+			ID:       "CPU_STARVED",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Waited %.1fms on the run queue for a free CPU", run.RunDelayMS),
+			Details:  fmt.Sprintf("run_delay_ms=%.1f wait_ms=%.1f wall_ms=%.1f cpu_ratio=%.2f", run.RunDelayMS, waitMS, run.WallMS, run.CPURatio),
+			Suggestions: []string{
+				"The machine was busy; check load, other jobs, or CPU quotas on shared runners",
+				"Re-run on an idle machine before treating this as I/O or a regression",
+			},
+		},
+	}
+}
+
+func majorPageFaults(run model.RunResult) []model.Explanation {
+	const threshold = 500
+	if run.MajorFaults < threshold {
+		return nil
+	}
+
 	return []model.Explanation{
 		{
-			ID:       "HIGH_IO_WAIT",
+			ID:       "MAJOR_PAGE_FAULTS",
 			Severity: "warn",
-			Message:  fmt.Sprintf("High wait time (~%.0f%% of wall)", waitRatio*100),
-			Details:  fmt.Sprintf("wait_ms=%.1f wall_ms=%.1f cpu_ratio=%.2f", waitMS, run.WallMS, run.CPURatio),
+			Message:  fmt.Sprintf("%d major page faults (pages read from disk)", run.MajorFaults),
+			Details:  fmt.Sprintf("major_faults=%d minor_faults=%d wall_ms=%.1f", run.MajorFaults, run.MinorFaults, run.WallMS),
 			Suggestions: []string{
-				"Check for disk/network latency, lock contention, or sleeps",
-				"Use tracing (strace/dtruss) if the wait is unexpected",
+				"Cold page cache, large mmapped files, or swapping; re-run to see if it goes away warm",
+				"Check free memory and swap activity if faults persist across runs",
+			},
+		},
+	}
+}
+
+func blockIOHeavy(run model.RunResult) []model.Explanation {
+	const threshold = 100000
+	total := run.InBlock + run.OutBlock
+	if total < threshold {
+		return nil
+	}
+
+	msg := "Heavy block I/O"
+	if run.CPURatio < 0.35 {
+		msg = "Heavy block I/O; wait time is likely disk"
+	}
+
+	return []model.Explanation{
+		{
+			ID:       "BLOCK_IO_HEAVY",
+			Severity: "warn",
+			Message:  msg,
+			Details:  fmt.Sprintf("in_block=%d out_block=%d cpu_ratio=%.2f", run.InBlock, run.OutBlock, run.CPURatio),
+			Suggestions: []string{
+				"Check which files are read or written and whether the page cache is cold",
+				"Batch small writes, avoid redundant fsyncs, or move data to faster storage",
+			},
+		},
+	}
+}
+
+func contextSwitchHeavy(run model.RunResult) []model.Explanation {
+	if run.WallMS <= 0 {
+		return nil
+	}
+	secs := run.WallMS / 1000
+	invRate := float64(run.InvolCtxSw) / secs
+	volRate := float64(run.VolCtxSw) / secs
+	details := fmt.Sprintf("voluntary=%d (%.0f/s) involuntary=%d (%.0f/s)", run.VolCtxSw, volRate, run.InvolCtxSw, invRate)
+
+	switch {
+	case run.InvolCtxSw >= 1000 && invRate >= 500:
+		return []model.Explanation{
+			{
+				ID:       "CONTEXT_SWITCH_HEAVY",
+				Severity: "warn",
+				Message:  "Many involuntary context switches; competing for CPU",
+				Details:  details,
+				Suggestions: []string{
+					"Other work is preempting the command; check machine load or CPU quotas",
+					"Reduce thread or process count if it exceeds available cores",
+				},
+			},
+		}
+	case run.VolCtxSw >= 5000 && volRate >= 2000:
+		return []model.Explanation{
+			{
+				ID:       "CONTEXT_SWITCH_HEAVY",
+				Severity: "info",
+				Message:  "Frequent voluntary context switches; blocking often",
+				Details:  details,
+				Suggestions: []string{
+					"Look for small reads/writes, lock handoffs, or short sleeps in a loop",
+					"Batch I/O or reduce synchronization between threads",
+				},
+			},
+		}
+	}
+	return nil
+}
+
+// storageVolume flags commands that move a lot of data to or from storage.
+// Effective throughput is bytes over wall time, so a low figure on a
+// WAIT_IO_BOUND run points at slow storage rather than at volume.
+func storageVolume(run model.RunResult) []model.Explanation {
+	const threshold = 1 << 30
+	if run.IO == nil || run.WallMS <= 0 {
+		return nil
+	}
+	io := run.IO
+	secs := run.WallMS / 1000
+	details := fmt.Sprintf("read_bytes=%d write_bytes=%d rchar=%d wchar=%d syscr=%d syscw=%d", io.ReadBytes, io.WriteBytes, io.ReadChars, io.WriteChars, io.ReadCalls, io.WriteCalls)
+
+	var out []model.Explanation
+	if io.ReadBytes >= threshold {
+		out = append(out, model.Explanation{
+			ID:       "STORAGE_READ_HEAVY",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Reads %.1f GB from storage (%.0f MB/s effective)", gib(io.ReadBytes), mib(io.ReadBytes)/secs),
+			Details:  details,
+			Suggestions: []string{
+				"Check whether the same data is read on every run and could be cached or skipped",
+				"Compare effective throughput with what the disk can do; far below means small or random reads",
+			},
+		})
+	}
+	if io.WriteBytes >= threshold {
+		out = append(out, model.Explanation{
+			ID:       "STORAGE_WRITE_HEAVY",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Writes %.1f GB to storage (%.0f MB/s effective)", gib(io.WriteBytes), mib(io.WriteBytes)/secs),
+			Details:  details,
+			Suggestions: []string{
+				"Look for temporary files, logs, or caches that do not need to hit disk",
+				"Write to tmpfs or batch output if durability is not needed",
+			},
+		})
+	}
+	return out
+}
+
+func ioNote(run model.RunResult) string {
+	io := run.IO
+	secs := run.WallMS / 1000
+	if secs <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("io: read %.1f MB from storage (%.1f MB/s), wrote %.1f MB (%.1f MB/s); %.1f MB read via syscalls incl. page cache",
+		mib(io.ReadBytes), mib(io.ReadBytes)/secs, mib(io.WriteBytes), mib(io.WriteBytes)/secs, mib(io.ReadChars))
+}
+
+func mib(b int64) float64 {
+	return float64(b) / (1 << 20)
+}
+
+func gib(b int64) float64 {
+	return float64(b) / (1 << 30)
+}
+
+func leftoverProcesses(run model.RunResult) []model.Explanation {
+	if len(run.Leftovers) == 0 {
+		return nil
+	}
+	running := 0
+	names := map[string]bool{}
+	var list []string
+	for _, p := range run.Leftovers {
+		if p.State == "running" {
+			running++
+		}
+		if !names[p.Comm] && len(list) < 5 {
+			names[p.Comm] = true
+			list = append(list, p.Comm)
+		}
+	}
+
+	severity := "info"
+	msg := fmt.Sprintf("%d descendant process(es) outlived the command", len(run.Leftovers))
+	if running > 0 {
+		severity = "warn"
+		msg = fmt.Sprintf("%d descendant process(es) still running after the command exited", running)
+	}
+
+	return []model.Explanation{
+		{
+			ID:       "LEFTOVER_PROCESSES",
+			Severity: severity,
+			Message:  msg,
+			Details:  fmt.Sprintf("policy=%s comm=%s", run.LeftoverPolicy, strings.Join(list, ",")),
+			Suggestions: []string{
+				"The command daemonised or backgrounded work; wall time does not include it",
+				"Use --wait-leftovers to measure until they finish, or --kill-leftovers to clean them up",
 			},
 		},
 	}
 }
 
 func memoryPressure(run model.RunResult) []model.Explanation {
+	if expl, ok := memoryComposition(run); ok {
+		return expl
+	}
 	threshold := memoryThreshold()
 	if threshold <= 0 {
 		return nil
@@ -160,8 +501,11 @@ func platformSupportsUsage(platform string) bool {
 
 // compare analysis explains two runs.
 func CompareAnalysis(a, b model.RunResult) model.Analysis {
+	if a.Cgroup != nil && b.Cgroup != nil {
+		a, b = preferCgroup(a), preferCgroup(b)
+	}
 	analysis := model.Analysis{
-		Classification: Classify(b.CPURatio),
+		Classification: classifyRun(b),
 	}
 	analysis.Explanations = append(analysis.Explanations, model.Explanation{
 		ID:       "COMPARISON_BASE",
@@ -185,6 +529,7 @@ func CompareAnalysis(a, b model.RunResult) model.Analysis {
 	analysis.Explanations = append(analysis.Explanations, cpuDelta...)
 	analysis.Explanations = append(analysis.Explanations, memRun...)
 	analysis.Explanations = append(analysis.Explanations, sysRun...)
+	analysis.Explanations = append(analysis.Explanations, compareTerminal(a, b)...)
 
 	if len(wallDelta) > 0 {
 		analysis.Notes = append(analysis.Notes, fmt.Sprintf("WALL_TIME_REGRESSION triggered for run %s", b.ID))
@@ -193,12 +538,16 @@ func CompareAnalysis(a, b model.RunResult) model.Analysis {
 		analysis.Notes = append(analysis.Notes, "MEMORY_PRESSURE triggered by delta between runs")
 	}
 	if len(memRun) > 0 {
-		analysis.Notes = append(analysis.Notes, "MEMORY_PRESSURE triggered for run B")
+		analysis.Notes = append(analysis.Notes, fmt.Sprintf("%s triggered for run B", memRun[0].ID))
 	}
 	if len(sysRun) > 0 {
 		analysis.Notes = append(analysis.Notes, fmt.Sprintf("HIGH_SYS_TIME triggered for run %s", b.ID))
 	}
 	analysis.Notes = append(analysis.Notes, rssUnitNote(b))
+	if poorEnvironment(a) || poorEnvironment(b) {
+		analysis.Notes = append(analysis.Notes, "NOISY_ENVIRONMENT: regressions down-ranked to info")
+		analysis.Explanations = downrankNoisy(a, b, analysis.Explanations)
+	}
 
 	return analysis
 }
@@ -1,6 +1,7 @@
 package analyze
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/barthollomew/why-is-this-slow/internal/model"
@@ -31,6 +32,58 @@ func TestIOWaitRule(t *testing.T) {
 	}
 }
 
+func TestIOwaitSplitsDiskAndSleep(t *testing.T) {
+	run := model.RunResult{
+		WallMS:     1000,
+		UserMS:     10,
+		SysMS:      10,
+		CPURatio:   0.02,
+		Platform:   "linux/amd64",
+		WaitStates: &model.WaitStates{DiskPolls: 8, SleepPolls: 1, Wchan: map[string]int{"folio_wait_bit": 8}},
+	}
+	expl := ioWait(run)
+	if len(expl) != 1 || expl[0].ID != "DISK_WAIT" {
+		t.Fatalf("expected DISK_WAIT, got %+v", expl)
+	}
+
+	run.WaitStates = &model.WaitStates{SleepPolls: 9, Wchan: map[string]int{"hrtimer_nanosleep": 9}}
+	expl = ioWait(run)
+	if len(expl) != 1 || expl[0].ID != "SLEEP_OR_POLL" {
+		t.Fatalf("expected SLEEP_OR_POLL, got %+v", expl)
+	}
+
+	run.WaitStates = &model.WaitStates{BlkioDelayMS: 600, SleepPolls: 2}
+	split := splitWait(run)
+	if split.DiskMS != 600 || split.SleepMS != 380 {
+		t.Fatalf("unexpected split %+v", split)
+	}
+}
+
+func TestStorageVolumeRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS: 10000,
+		IO:     &model.IOCounters{ReadBytes: 3 << 30, ReadChars: 3 << 30},
+	}
+	expl := storageVolume(run)
+	if len(expl) != 1 || expl[0].ID != "STORAGE_READ_HEAVY" {
+		t.Fatalf("expected STORAGE_READ_HEAVY, got %+v", expl)
+	}
+	if !strings.Contains(expl[0].Message, "3.0 GB") {
+		t.Fatalf("expected size in message, got %q", expl[0].Message)
+	}
+}
+
+func TestLeftoverProcessesRule(t *testing.T) {
+	run := model.RunResult{
+		LeftoverPolicy: "report",
+		Leftovers:      []model.LeftoverProcess{{PID: 10, Comm: "daemon", State: "running"}},
+	}
+	expl := leftoverProcesses(run)
+	if len(expl) != 1 || expl[0].Severity != "warn" {
+		t.Fatalf("expected leftover warning, got %+v", expl)
+	}
+}
+
 func TestMemoryPressureRule(t *testing.T) {
 	thr := memoryThreshold()
 	if thr == 0 {
@@ -46,6 +99,58 @@ func TestMemoryPressureRule(t *testing.T) {
 	}
 }
 
+func TestCPUStarvedRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS:     1000,
+		UserMS:     200,
+		CPURatio:   0.2,
+		RunDelayMS: 600,
+		Platform:   "linux/amd64",
+	}
+	if expl := cpuStarved(run); len(expl) == 0 {
+		t.Fatalf("expected cpu starved explanation")
+	}
+	if got := AnalyzeRun(run).Classification; got != ClassificationStarved {
+		t.Fatalf("classification = %s, want %s", got, ClassificationStarved)
+	}
+	run.RunDelayMS = 10
+	if got := AnalyzeRun(run).Classification; got != ClassificationWaitIO {
+		t.Fatalf("classification = %s, want %s", got, ClassificationWaitIO)
+	}
+}
+
+func TestMajorPageFaultsRule(t *testing.T) {
+	run := model.RunResult{WallMS: 1000, MajorFaults: 2000}
+	if expl := majorPageFaults(run); len(expl) == 0 {
+		t.Fatalf("expected major page fault explanation")
+	}
+	run.MajorFaults = 3
+	if expl := majorPageFaults(run); len(expl) != 0 {
+		t.Fatalf("unexpected explanation for few faults")
+	}
+}
+
+func TestBlockIOHeavyRule(t *testing.T) {
+	run := model.RunResult{WallMS: 1000, InBlock: 400000, CPURatio: 0.1}
+	expl := blockIOHeavy(run)
+	if len(expl) == 0 {
+		t.Fatalf("expected block io explanation")
+	}
+}
+
+func TestContextSwitchHeavyRule(t *testing.T) {
+	run := model.RunResult{WallMS: 1000, InvolCtxSw: 5000, VolCtxSw: 10}
+	expl := contextSwitchHeavy(run)
+	if len(expl) == 0 || expl[0].Severity != "warn" {
+		t.Fatalf("expected involuntary switch warning, got %+v", expl)
+	}
+	run = model.RunResult{WallMS: 1000, InvolCtxSw: 5, VolCtxSw: 10000}
+	expl = contextSwitchHeavy(run)
+	if len(expl) == 0 || expl[0].Severity != "info" {
+		t.Fatalf("expected voluntary switch note, got %+v", expl)
+	}
+}
+
 func TestCompareMemoryIncrease(t *testing.T) {
 	a := model.RunResult{ID: "a", MaxRSSRaw: 100}
 	b := model.RunResult{ID: "b", MaxRSSRaw: 140}
@@ -0,0 +1,211 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+var (
+	sleepCalls = []string{"nanosleep", "clock_nanosleep"}
+	pollCalls  = []string{"poll", "ppoll", "select", "pselect6", "epoll_wait", "epoll_pwait", "epoll_pwait2"}
+	syncCalls  = []string{"fsync", "fdatasync", "sync_file_range", "sync", "syncfs", "msync"}
+	// lookupCalls resolve a path; fstat and friends on an open fd are not
+	// included.
+	lookupCalls = []string{
+		"stat", "lstat", "newfstatat", "statx",
+		"access", "faccessat", "faccessat2",
+		"open", "openat", "openat2",
+		"readlink", "readlinkat",
+	}
+)
+
+// syscallGroup sums the stats of the named syscalls.
+func syscallGroup(stats []model.SyscallStat, names []string) model.SyscallStat {
+	var out model.SyscallStat
+	for _, st := range stats {
+		for _, name := range names {
+			if st.Name != name {
+				continue
+			}
+			out.Count += st.Count
+			out.Errors += st.Errors
+			out.TotalMS += st.TotalMS
+			out.MaxMS = max(out.MaxMS, st.MaxMS)
+			out.Timeouts += st.Timeouts
+			out.TimeoutMS += st.TimeoutMS
+		}
+	}
+	return out
+}
+
+func totalSyscalls(stats []model.SyscallStat) int64 {
+	var n int64
+	for _, st := range stats {
+		n += st.Count
+	}
+	return n
+}
+
+func syscallRules(run model.RunResult) []model.Explanation {
+	if len(run.Syscalls) == 0 || run.WallMS <= 0 {
+		return nil
+	}
+	var out []model.Explanation
+	out = append(out, sleepDominated(run)...)
+	out = append(out, fsyncHeavy(run)...)
+	out = append(out, excessiveStat(run)...)
+	out = append(out, futexContention(run)...)
+	return out
+}
+
+// sleepDominated flags time spent in explicit sleeps and in poll-style calls
+// that ran into their timeout. Syscall time is summed across threads, so it
+// can exceed wall time.
+func sleepDominated(run model.RunResult) []model.Explanation {
+	sleeps := syscallGroup(run.Syscalls, sleepCalls)
+	polls := syscallGroup(run.Syscalls, pollCalls)
+	sleepMS := sleeps.TotalMS + polls.TimeoutMS
+	if sleepMS < 50 || sleepMS < run.WallMS/2 {
+		return nil
+	}
+	return []model.Explanation{
+		{
+			ID:       "SLEEP_DOMINATED",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Sleeping or waiting out timeouts for %.0fms (wall %.0fms)", sleepMS, run.WallMS),
+			Details:  fmt.Sprintf("sleep_calls=%d sleep_ms=%.1f poll_timeouts=%d poll_timeout_ms=%.1f summed across threads", sleeps.Count, sleeps.TotalMS, polls.Timeouts, polls.TimeoutMS),
+			Suggestions: []string{
+				"Look for retry loops, fixed sleeps, or backoff that waits longer than needed",
+				"Poll timeouts usually mean waiting for a peer or child that is slow to answer",
+			},
+		},
+	}
+}
+
+func fsyncHeavy(run model.RunResult) []model.Explanation {
+	syncs := syscallGroup(run.Syscalls, syncCalls)
+	if syncs.TotalMS < 20 || syncs.TotalMS < run.WallMS*0.10 {
+		return nil
+	}
+	return []model.Explanation{
+		{
+			ID:       "FSYNC_HEAVY",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Flushing to disk took %.0fms (~%.0f%% of wall)", syncs.TotalMS, ratio(syncs.TotalMS, run.WallMS)*100),
+			Details:  fmt.Sprintf("sync_calls=%d sync_ms=%.1f max_ms=%.1f", syncs.Count, syncs.TotalMS, syncs.MaxMS),
+			Suggestions: []string{
+				"Batch writes and sync once instead of after every write",
+				"For tests and scratch data, disable durability (e.g. SQLite synchronous=OFF, eatmydata)",
+				"Check whether the volume has a slow write cache or is network-backed",
+			},
+		},
+	}
+}
+
+func excessiveStat(run model.RunResult) []model.Explanation {
+	lookups := syscallGroup(run.Syscalls, lookupCalls)
+	if lookups.Count < 5000 {
+		return nil
+	}
+	share := ratio(float64(lookups.Count), float64(totalSyscalls(run.Syscalls)))
+	if share < 0.30 && lookups.TotalMS < run.WallMS*0.10 {
+		return nil
+	}
+	return []model.Explanation{
+		{
+			ID:       "EXCESSIVE_STAT",
+			Severity: "warn",
+			Message:  fmt.Sprintf("%d path lookups (stat/open/access), %.0f%% of syscalls", lookups.Count, share*100),
+			Details:  fmt.Sprintf("lookup_calls=%d failed=%d lookup_ms=%.1f", lookups.Count, lookups.Errors, lookups.TotalMS),
+			Suggestions: []string{
+				"Many failed lookups usually mean search-path probing (module resolution, PATH, include dirs)",
+				"Shorten search paths or cache resolution results",
+				"Check for huge or network-mounted directories on the search path",
+				"Re-run with --trace-files to see which directories are hit",
+			},
+		},
+	}
+}
+
+func futexContention(run model.RunResult) []model.Explanation {
+	futex := syscallGroup(run.Syscalls, []string{"futex", "futex_waitv", "futex_wait", "futex_wake"})
+	perSec := float64(futex.Count) / (run.WallMS / 1000)
+	if futex.Count < 2000 || perSec < 1000 {
+		return nil
+	}
+	return []model.Explanation{
+		{
+			ID:       "FUTEX_CONTENTION",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Threads hit contended locks %.0f times/s", perSec),
+			Details:  fmt.Sprintf("futex_calls=%d futex_ms=%.1f max_ms=%.1f eagain_or_errors=%d summed across threads", futex.Count, futex.TotalMS, futex.MaxMS, futex.Errors),
+			Suggestions: []string{
+				"Find the hot lock: a shared allocator, logger, or global map are common culprits",
+				"Reduce the thread count or shard the contended state",
+			},
+		},
+	}
+}
+
+// SyscallDeltas lists how syscall counts changed from run a to run b, largest
+// change first. It is empty unless both runs were traced with --syscalls.
+func SyscallDeltas(a, b model.RunResult) []model.SyscallDelta {
+	if len(a.Syscalls) == 0 || len(b.Syscalls) == 0 {
+		return nil
+	}
+	byName := map[string]*model.SyscallDelta{}
+	var out []*model.SyscallDelta
+	get := func(name string) *model.SyscallDelta {
+		d := byName[name]
+		if d == nil {
+			d = &model.SyscallDelta{Name: name}
+			byName[name] = d
+			out = append(out, d)
+		}
+		return d
+	}
+	for _, st := range a.Syscalls {
+		d := get(st.Name)
+		d.CountA = st.Count
+		d.TotalMSA = st.TotalMS
+	}
+	for _, st := range b.Syscalls {
+		d := get(st.Name)
+		d.CountB = st.Count
+		d.TotalMSB = st.TotalMS
+	}
+
+	deltas := make([]model.SyscallDelta, 0, len(out))
+	for _, d := range out {
+		d.Delta = d.CountB - d.CountA
+		if d.Delta != 0 {
+			deltas = append(deltas, *d)
+		}
+	}
+	sort.SliceStable(deltas, func(i, j int) bool {
+		return abs64(deltas[i].Delta) > abs64(deltas[j].Delta)
+	})
+	return deltas
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// topSyscalls names the syscalls with the most time, for one-line summaries.
+func topSyscalls(stats []model.SyscallStat, n int) string {
+	var parts []string
+	for i, st := range stats {
+		if i >= n {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s:%.1fms", st.Name, st.TotalMS))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,85 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestSleepDominatedRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS: 1000,
+		Syscalls: []model.SyscallStat{
+			{Name: "clock_nanosleep", Count: 3, TotalMS: 300},
+			{Name: "epoll_pwait", Count: 10, TotalMS: 400, Timeouts: 4, TimeoutMS: 350},
+			{Name: "read", Count: 100, TotalMS: 20},
+		},
+	}
+	if expl := sleepDominated(run); len(expl) == 0 {
+		t.Fatalf("expected sleep dominated explanation")
+	}
+	run.Syscalls[1].TimeoutMS = 0
+	if expl := sleepDominated(run); len(expl) != 0 {
+		t.Fatalf("polls that returned events should not count as sleep: %+v", expl)
+	}
+}
+
+func TestFsyncHeavyRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS:   1000,
+		Syscalls: []model.SyscallStat{{Name: "fdatasync", Count: 400, TotalMS: 250, MaxMS: 9}},
+	}
+	if expl := fsyncHeavy(run); len(expl) == 0 {
+		t.Fatalf("expected fsync heavy explanation")
+	}
+}
+
+func TestExcessiveStatRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS: 1000,
+		Syscalls: []model.SyscallStat{
+			{Name: "newfstatat", Count: 6000, Errors: 5500, TotalMS: 30},
+			{Name: "openat", Count: 2000, Errors: 100, TotalMS: 20},
+			{Name: "read", Count: 3000, TotalMS: 40},
+		},
+	}
+	if expl := excessiveStat(run); len(expl) == 0 {
+		t.Fatalf("expected excessive stat explanation")
+	}
+	run.Syscalls[0].Count = 1000
+	if expl := excessiveStat(run); len(expl) != 0 {
+		t.Fatalf("did not expect excessive stat for 3000 lookups: %+v", expl)
+	}
+}
+
+func TestFutexContentionRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS:   1000,
+		Syscalls: []model.SyscallStat{{Name: "futex", Count: 50000, Errors: 2000, TotalMS: 1800}},
+	}
+	if expl := futexContention(run); len(expl) == 0 {
+		t.Fatalf("expected futex contention explanation")
+	}
+	run.WallMS = 100000
+	if expl := futexContention(run); len(expl) != 0 {
+		t.Fatalf("did not expect contention at 500 calls/s: %+v", expl)
+	}
+}
+
+func TestSyscallDeltas(t *testing.T) {
+	a := model.RunResult{Syscalls: []model.SyscallStat{{Name: "read", Count: 100}, {Name: "stat", Count: 10}, {Name: "close", Count: 5}}}
+	b := model.RunResult{Syscalls: []model.SyscallStat{{Name: "read", Count: 90}, {Name: "stat", Count: 900}, {Name: "close", Count: 5}, {Name: "fsync", Count: 20}}}
+	deltas := SyscallDeltas(a, b)
+	if len(deltas) != 3 {
+		t.Fatalf("expected 3 changed syscalls, got %+v", deltas)
+	}
+	if deltas[0].Name != "stat" || deltas[0].Delta != 890 {
+		t.Fatalf("expected stat first, got %+v", deltas[0])
+	}
+	if deltas[1].Name != "fsync" || deltas[1].CountA != 0 || deltas[2].Delta != -10 {
+		t.Fatalf("unexpected order %+v", deltas)
+	}
+	if SyscallDeltas(model.RunResult{}, b) != nil {
+		t.Fatalf("expected no deltas when run A was not traced")
+	}
+}
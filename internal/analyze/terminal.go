@@ -0,0 +1,92 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+const (
+	outputBytesThreshold = 10 << 20
+	outputLinesThreshold = 100000
+)
+
+// outputVolume is what a run wrote to both streams, and whether it is
+// enough for the destination's speed to matter.
+func outputVolume(o *model.Output) (bytes, lines int64, large bool) {
+	bytes = o.Stdout.Bytes + o.Stderr.Bytes
+	lines = o.Stdout.Lines + o.Stderr.Lines
+	return bytes, lines, bytes >= outputBytesThreshold || lines >= outputLinesThreshold
+}
+
+func outputDest(o *model.Output) string {
+	if o.Terminal {
+		return "the terminal"
+	}
+	return "stdout"
+}
+
+// terminalOutputBound fires when relaying a large amount of output blocked
+// for a good part of the run: the command was waiting on whatever displays
+// or stores its output.
+func terminalOutputBound(run model.RunResult) []model.Explanation {
+	o := run.Output
+	if o == nil || o.Mode != "inherit" || run.WallMS <= 0 {
+		return nil
+	}
+	bytes, lines, large := outputVolume(o)
+	blocked := o.Stdout.BlockedMS + o.Stderr.BlockedMS
+	if !large || blocked < 50 || blocked < run.WallMS*0.20 {
+		return nil
+	}
+	return []model.Explanation{
+		{
+			ID:       "TERMINAL_OUTPUT_BOUND",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Writing %.1f MB (%d lines) to %s blocked for %.0fms (~%.0f%% of wall)", mib(bytes), lines, outputDest(o), blocked, ratio(blocked, run.WallMS)*100),
+			Details:  outputDetails(o),
+			Suggestions: []string{
+				"Re-run with --stdout=null and compare to see the time without the terminal",
+				"Redirect output to a file or pipe it through less or tail instead of scrolling it",
+				"Lower the log level or drop per-item progress output",
+			},
+		},
+	}
+}
+
+func outputDetails(o *model.Output) string {
+	return fmt.Sprintf("mode=%s terminal=%t stdout_bytes=%d stdout_lines=%d stdout_blocked_ms=%.1f stderr_bytes=%d stderr_lines=%d stderr_blocked_ms=%.1f",
+		o.Mode, o.Terminal, o.Stdout.Bytes, o.Stdout.Lines, o.Stdout.BlockedMS, o.Stderr.Bytes, o.Stderr.Lines, o.Stderr.BlockedMS)
+}
+
+// compareTerminal fires when one run printed a lot to the terminal, the
+// other discarded or captured stdout, and that one was clearly faster.
+func compareTerminal(a, b model.RunResult) []model.Explanation {
+	if a.Output == nil || b.Output == nil || a.Output.Mode == b.Output.Mode {
+		return nil
+	}
+	shown, hidden := a, b
+	if b.Output.Mode == "inherit" {
+		shown, hidden = b, a
+	}
+	if shown.Output.Mode != "inherit" || shown.WallMS <= 0 {
+		return nil
+	}
+	bytes, lines, large := outputVolume(shown.Output)
+	saved := shown.WallMS - hidden.WallMS
+	if !large || saved < 50 || saved < shown.WallMS*0.20 {
+		return nil
+	}
+	return []model.Explanation{
+		{
+			ID:       "TERMINAL_OUTPUT_BOUND",
+			Severity: "warn",
+			Message:  fmt.Sprintf("Wall time drops %.0f%% with --stdout=%s (%.1fms vs %.1fms relaying %.1f MB to %s)", saved/shown.WallMS*100, hidden.Output.Mode, hidden.WallMS, shown.WallMS, mib(bytes), outputDest(shown.Output)),
+			Details:  fmt.Sprintf("lines=%d saved_ms=%.1f %s", lines, saved, outputDetails(shown.Output)),
+			Suggestions: []string{
+				"The difference is the terminal rendering output, not the command; compare runs with the same --stdout",
+				"Redirect output to a file or lower the log level when timing this command",
+			},
+		},
+	}
+}
@@ -0,0 +1,39 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestTerminalOutputBoundRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS: 2000,
+		Output: &model.Output{
+			Mode:     "inherit",
+			Terminal: true,
+			Stdout:   model.StreamStats{Bytes: 200 << 20, Lines: 3000000, BlockedMS: 1500},
+		},
+	}
+	if expl := terminalOutputBound(run); len(expl) != 1 || expl[0].ID != "TERMINAL_OUTPUT_BOUND" {
+		t.Fatalf("expected TERMINAL_OUTPUT_BOUND, got %+v", expl)
+	}
+	run.Output.Stdout.BlockedMS = 20
+	if expl := terminalOutputBound(run); len(expl) != 0 {
+		t.Fatalf("a fast terminal is not the bottleneck: %+v", expl)
+	}
+}
+
+func TestCompareTerminal(t *testing.T) {
+	shown := model.RunResult{WallMS: 2000, Output: &model.Output{Mode: "inherit", Stdout: model.StreamStats{Bytes: 200 << 20, Lines: 3000000}}}
+	hidden := model.RunResult{WallMS: 400, Output: &model.Output{Mode: "null", Stdout: model.StreamStats{Bytes: 200 << 20, Lines: 3000000}}}
+	for _, pair := range [][2]model.RunResult{{shown, hidden}, {hidden, shown}} {
+		if expl := compareTerminal(pair[0], pair[1]); len(expl) != 1 {
+			t.Fatalf("expected TERMINAL_OUTPUT_BOUND either way round, got %+v", expl)
+		}
+	}
+	hidden.Output.Mode = "inherit"
+	if expl := compareTerminal(shown, hidden); len(expl) != 0 {
+		t.Fatalf("same mode should not compare terminals: %+v", expl)
+	}
+}
@@ -0,0 +1,107 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+const (
+	idleCPUPercent   = 5.0
+	minIdleStretchMS = 250.0
+)
+
+// SlowestTimeline returns the slowest sample that carries a timeline. The
+// slowest sample is the one most worth explaining when repeats disagree.
+func SlowestTimeline(run model.RunResult) (model.Sample, bool) {
+	var best model.Sample
+	found := false
+	for _, s := range run.RawSamples {
+		if len(s.Timeline) == 0 {
+			continue
+		}
+		if !found || s.WallMS > best.WallMS {
+			best = s
+			found = true
+		}
+	}
+	return best, found
+}
+
+// SummarizeTimeline finds peaks and stretches where the tree was nearly idle.
+// Each point covers the interval since the previous point.
+func SummarizeTimeline(points []model.TimelinePoint) model.TimelineSummary {
+	sum := model.TimelineSummary{Points: len(points)}
+	if len(points) == 0 {
+		return sum
+	}
+	sum.DurationMS = points[len(points)-1].OffsetMS
+
+	idleStart := -1.0
+	prevOffset := 0.0
+	closeIdle := func(end float64) {
+		if idleStart >= 0 && end-idleStart >= minIdleStretchMS {
+			sum.Idle = append(sum.Idle, model.IdleStretch{StartMS: idleStart, EndMS: end})
+			sum.IdleMS += end - idleStart
+		}
+		idleStart = -1
+	}
+
+	for _, p := range points {
+		if p.CPUPercent > sum.PeakCPUPercent {
+			sum.PeakCPUPercent = p.CPUPercent
+			sum.PeakCPUAtMS = p.OffsetMS
+		}
+		if p.RSSKB > sum.PeakRSSKB {
+			sum.PeakRSSKB = p.RSSKB
+			sum.PeakRSSAtMS = p.OffsetMS
+		}
+		sum.PeakThreads = max(sum.PeakThreads, p.Threads)
+		sum.PeakOpenFDs = max(sum.PeakOpenFDs, p.OpenFDs)
+		sum.PeakProcs = max(sum.PeakProcs, p.Procs)
+
+		if p.CPUPercent < idleCPUPercent {
+			if idleStart < 0 {
+				idleStart = prevOffset
+			}
+		} else {
+			closeIdle(prevOffset)
+		}
+		prevOffset = p.OffsetMS
+	}
+	closeIdle(prevOffset)
+
+	return sum
+}
+
+func idleStretches(run model.RunResult) []model.Explanation {
+	sample, ok := SlowestTimeline(run)
+	if !ok || sample.WallMS <= 0 {
+		return nil
+	}
+	sum := SummarizeTimeline(sample.Timeline)
+	share := sum.IdleMS / sample.WallMS
+	if share < 0.30 || len(sum.Idle) == 0 {
+		return nil
+	}
+
+	longest := sum.Idle[0]
+	for _, st := range sum.Idle[1:] {
+		if st.EndMS-st.StartMS > longest.EndMS-longest.StartMS {
+			longest = st
+		}
+	}
+
+	return []model.Explanation{
+		{
+			ID:       "IDLE_STRETCHES",
+			Severity: "info",
+			Message:  fmt.Sprintf("Process tree idle for ~%.0f%% of the run", share*100),
+			Details:  fmt.Sprintf("idle_ms=%.1f stretches=%d longest=%.1fms at +%.1fms", sum.IdleMS, len(sum.Idle), longest.EndMS-longest.StartMS, longest.StartMS),
+			Suggestions: []string{
+				"Check what the command waits on during the idle stretches (network, locks, sleeps)",
+				"Compare idle offsets with log timestamps to find the blocking step",
+			},
+		},
+	}
+}
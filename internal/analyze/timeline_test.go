@@ -0,0 +1,33 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestSummarizeTimelineIdle(t *testing.T) {
+	var points []model.TimelinePoint
+	for i := 1; i <= 20; i++ {
+		cpu := 90.0
+		if i > 5 && i <= 15 {
+			cpu = 0
+		}
+		points = append(points, model.TimelinePoint{OffsetMS: float64(i * 100), CPUPercent: cpu, RSSKB: int64(i), Procs: 1})
+	}
+	sum := SummarizeTimeline(points)
+	if len(sum.Idle) != 1 {
+		t.Fatalf("expected one idle stretch, got %+v", sum.Idle)
+	}
+	if sum.Idle[0].StartMS != 500 || sum.Idle[0].EndMS != 1500 {
+		t.Fatalf("unexpected idle stretch %+v", sum.Idle[0])
+	}
+	if sum.PeakRSSKB != 20 || sum.PeakRSSAtMS != 2000 {
+		t.Fatalf("unexpected rss peak %+v", sum)
+	}
+
+	run := model.RunResult{RawSamples: []model.Sample{{WallMS: 2000, Timeline: points}}}
+	if expl := idleStretches(run); len(expl) == 0 {
+		t.Fatalf("expected idle stretches explanation")
+	}
+}
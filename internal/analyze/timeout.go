@@ -0,0 +1,37 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// timedOut reports samples stopped by --timeout. Their wall time is the
+// timeout rather than the command's, so they are left out of the stats;
+// when every sample timed out, the stats are a lower bound.
+func timedOut(run model.RunResult) []model.Explanation {
+	if run.TimedOut == 0 {
+		return nil
+	}
+	total := max(len(run.RawSamples), 1)
+	suggestions := []string{
+		"Look at what the command was waiting for: rerun with --sample-interval 100ms or --syscalls to see where it stalls",
+		"Check for prompts on stdin, locks held by another process, or network calls without their own timeout",
+	}
+	if run.TimedOut < total {
+		suggestions = append(suggestions, "Only some samples hung, so suspect a race or contention rather than a command that never finishes")
+	}
+	effect := "excluded from the stats"
+	if run.TimedOut >= total {
+		effect = "wall time is a lower bound"
+	}
+	return []model.Explanation{
+		{
+			ID:          "TIMEOUT",
+			Severity:    "critical",
+			Message:     fmt.Sprintf("%d of %d samples hit the %.1fs timeout and were stopped; %s", run.TimedOut, total, run.TimeoutMS/1000, effect),
+			Details:     fmt.Sprintf("timed_out=%d samples=%d timeout_ms=%.0f", run.TimedOut, total, run.TimeoutMS),
+			Suggestions: suggestions,
+		},
+	}
+}
@@ -0,0 +1,27 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestTimeoutRule(t *testing.T) {
+	run := model.RunResult{
+		WallMS:     30000,
+		TimeoutMS:  30000,
+		TimedOut:   1,
+		RawSamples: []model.Sample{{WallMS: 30000, TimedOut: true}, {WallMS: 1200}, {WallMS: 1100}},
+	}
+	analysis := AnalyzeRun(run)
+	if analysis.Explanations[1].ID != "TIMEOUT" || analysis.Explanations[1].Severity != "critical" {
+		t.Fatalf("expected TIMEOUT after the baseline, got %+v", analysis.Explanations)
+	}
+	if len(analysis.Explanations[1].Suggestions) != 3 {
+		t.Fatalf("a partial timeout should suggest a race: %+v", analysis.Explanations[1].Suggestions)
+	}
+	run.TimedOut = 0
+	if expl := timedOut(run); len(expl) != 0 {
+		t.Fatalf("unexpected TIMEOUT: %+v", expl)
+	}
+}
@@ -0,0 +1,57 @@
+package analyze
+
+import (
+	"sort"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// SlowestTree returns the slowest sample that carries a traced process tree.
+func SlowestTree(run model.RunResult) (model.Sample, bool) {
+	var best model.Sample
+	found := false
+	for _, s := range run.RawSamples {
+		if len(s.ProcTree) == 0 {
+			continue
+		}
+		if !found || s.WallMS > best.WallMS {
+			best = s
+			found = true
+		}
+	}
+	return best, found
+}
+
+// AggregateExecutables groups a process tree by executable name, most CPU
+// first. Wall time is summed per process, so a parent that waits on its
+// children counts that time too.
+func AggregateExecutables(nodes []model.ProcNode) []model.ExeStat {
+	index := map[string]int{}
+	var out []model.ExeStat
+	for _, n := range nodes {
+		name := n.Exe
+		if name == "" {
+			name = "?"
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(out)
+			index[name] = i
+			out = append(out, model.ExeStat{Exe: name})
+		}
+		st := &out[i]
+		st.Count++
+		if n.EndMS > n.StartMS {
+			st.WallMS += n.EndMS - n.StartMS
+		}
+		st.CPUMS += n.UserMS + n.SysMS
+		st.MaxRSSKB = max(st.MaxRSSKB, n.MaxRSSKB)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].CPUMS != out[j].CPUMS {
+			return out[i].CPUMS > out[j].CPUMS
+		}
+		return out[i].WallMS > out[j].WallMS
+	})
+	return out
+}
@@ -0,0 +1,32 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestAggregateExecutables(t *testing.T) {
+	nodes := []model.ProcNode{
+		{PID: 1, Exe: "sh", StartMS: 0, EndMS: 1000, UserMS: 5},
+		{PID: 2, PPID: 1, Exe: "sed", StartMS: 10, EndMS: 20, UserMS: 4, SysMS: 2},
+		{PID: 3, PPID: 1, Exe: "sed", StartMS: 20, EndMS: 35, UserMS: 5, SysMS: 1, MaxRSSKB: 900},
+		{PID: 4, PPID: 1, Exe: "cc1", StartMS: 40, EndMS: 900, UserMS: 800, SysMS: 40, MaxRSSKB: 50000},
+	}
+	stats := AggregateExecutables(nodes)
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 executables, got %+v", stats)
+	}
+	if stats[0].Exe != "cc1" || stats[1].Exe != "sed" || stats[2].Exe != "sh" {
+		t.Fatalf("expected most CPU first, got %+v", stats)
+	}
+	sed := stats[1]
+	if sed.Count != 2 || sed.WallMS != 25 || sed.CPUMS != 12 || sed.MaxRSSKB != 900 {
+		t.Fatalf("unexpected sed aggregate %+v", sed)
+	}
+
+	run := model.RunResult{RawSamples: []model.Sample{{WallMS: 10}, {WallMS: 5, ProcTree: nodes}}}
+	if s, ok := SlowestTree(run); !ok || len(s.ProcTree) != 4 {
+		t.Fatalf("expected the traced sample, got %+v %v", s, ok)
+	}
+}
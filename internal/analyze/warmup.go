@@ -0,0 +1,72 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/stats"
+)
+
+// firstRunPenalty fires when the first sample was much slower than the
+// measured ones: warmups if there were any, otherwise the first of at least
+// three measured samples, which then skews the median.
+func firstRunPenalty(run model.RunResult) []model.Explanation {
+	var first model.Sample
+	var rest []float64
+	hint := "Warmup samples are already excluded from the stats"
+	switch {
+	case len(run.Warmup) > 0:
+		first = run.Warmup[0]
+		for _, s := range run.RawSamples {
+			if !s.CutShort() {
+				rest = append(rest, s.WallMS)
+			}
+		}
+	case len(run.RawSamples) >= 3:
+		first = run.RawSamples[0]
+		for _, s := range run.RawSamples[1:] {
+			if !s.CutShort() {
+				rest = append(rest, s.WallMS)
+			}
+		}
+		hint = "Re-run with --warmup 1 so the cold run stays out of the median and p90"
+	default:
+		return nil
+	}
+	median := stats.Median(rest)
+	// A first sample stopped by a timeout or signal says nothing about warmup.
+	if first.CutShort() || median <= 0 || first.WallMS < median*1.5 || first.WallMS-median < 20 {
+		return nil
+	}
+
+	cause := "caches or JIT warming up"
+	var steady int64
+	for _, s := range run.RawSamples {
+		steady = max(steady, s.MajorFaults+s.InBlock)
+	}
+	if first.MajorFaults+first.InBlock > steady*2+100 {
+		cause = "a cold page cache (more major faults and block reads)"
+	} else if first.UserMS > 0 && first.UserMS > stats.Median(sampleUser(run.RawSamples))*1.5 {
+		cause = "extra CPU work on the first run, typical of JIT compilation or cache building"
+	}
+	return []model.Explanation{
+		{
+			ID:       "FIRST_RUN_PENALTY",
+			Severity: "info",
+			Message:  fmt.Sprintf("First run took %.1fms, %.1fx the %.1fms median of later runs; likely %s", first.WallMS, first.WallMS/median, median, cause),
+			Details:  fmt.Sprintf("first_wall_ms=%.1f median_wall_ms=%.1f first_user_ms=%.1f first_major_faults=%d first_in_block=%d warmups=%d", first.WallMS, median, first.UserMS, first.MajorFaults, first.InBlock, len(run.Warmup)),
+			Suggestions: []string{
+				hint,
+				"If users see the cold run (CI, fresh containers, CLI startup), optimise that case: persist caches, precompile, trim startup work",
+			},
+		},
+	}
+}
+
+func sampleUser(samples []model.Sample) []float64 {
+	out := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		out = append(out, s.UserMS)
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestFirstRunPenaltyRule(t *testing.T) {
+	run := model.RunResult{
+		Warmup:     []model.Sample{{WallMS: 900, MajorFaults: 2000}},
+		RawSamples: []model.Sample{{WallMS: 200}, {WallMS: 210}},
+	}
+	expl := firstRunPenalty(run)
+	if len(expl) != 1 || expl[0].ID != "FIRST_RUN_PENALTY" || !strings.Contains(expl[0].Message, "page cache") {
+		t.Fatalf("expected a page-cache FIRST_RUN_PENALTY, got %+v", expl)
+	}
+
+	// Without warmups the first measured sample is the cold one.
+	run = model.RunResult{RawSamples: []model.Sample{{WallMS: 900, UserMS: 800}, {WallMS: 200, UserMS: 150}, {WallMS: 210, UserMS: 160}}}
+	expl = firstRunPenalty(run)
+	if len(expl) != 1 || !strings.Contains(expl[0].Suggestions[0], "--warmup") {
+		t.Fatalf("expected a --warmup suggestion, got %+v", expl)
+	}
+
+	run.RawSamples[0].WallMS = 220
+	if expl := firstRunPenalty(run); len(expl) != 0 {
+		t.Fatalf("steady runs should not fire: %+v", expl)
+	}
+}
@@ -0,0 +1,189 @@
+// Package cgroup reads cgroup v2 accounting: of the transient groups samples
+// run in with --cgroup, and of the enclosing group whose limits apply to the
+// tool itself.
+package cgroup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// ErrUnsupported is returned where cgroup v2 is not available.
+var ErrUnsupported = errors.New("cgroups are only supported on Linux")
+
+// Parent is the cgroup the per-sample groups are created in: the one the
+// calling process runs in, which must be delegated to the current user.
+type Parent struct {
+	dir string
+	seq int
+	err error // why controllers could not be enabled
+}
+
+// Group is one sample's transient cgroup.
+type Group struct {
+	dir string
+	fd  int
+	err error
+}
+
+// ParseKeyed parses flat "key value" files such as cpu.stat and
+// memory.events.
+func ParseKeyed(data string) map[string]int64 {
+	out := map[string]int64{}
+	for _, line := range strings.Split(data, "\n") {
+		key, val, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64); err == nil {
+			out[key] = n
+		}
+	}
+	return out
+}
+
+// IOStat is io.stat summed over devices.
+type IOStat struct {
+	ReadBytes  int64
+	WriteBytes int64
+	ReadOps    int64
+	WriteOps   int64
+}
+
+// ParseIOStat parses io.stat lines of the form
+// "8:0 rbytes=1 wbytes=2 rios=3 wios=4 dbytes=0 dios=0".
+func ParseIOStat(data string) IOStat {
+	var st IOStat
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, f := range fields[1:] {
+			key, val, ok := strings.Cut(f, "=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				st.ReadBytes += n
+			case "wbytes":
+				st.WriteBytes += n
+			case "rios":
+				st.ReadOps += n
+			case "wios":
+				st.WriteOps += n
+			}
+		}
+	}
+	return st
+}
+
+// ParseProcCgroup returns the cgroup v2 path from the content of
+// /proc/<pid>/cgroup, which is the "0::" line.
+func ParseProcCgroup(data string) (string, bool) {
+	for _, line := range strings.Split(data, "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// ParseMountinfo returns where cgroup2 is mounted, given the content of
+// /proc/self/mountinfo. Hybrid systems mount it at /sys/fs/cgroup/unified.
+func ParseMountinfo(data string) (string, bool) {
+	for _, line := range strings.Split(data, "\n") {
+		pre, post, ok := strings.Cut(line, " - ")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(pre)
+		if len(fields) < 5 || !strings.HasPrefix(post, "cgroup2 ") {
+			continue
+		}
+		return fields[4], true
+	}
+	return "", false
+}
+
+// Path returns the directory of the calling process's cgroup.
+func Path() (string, error) {
+	mnt, rel, err := locate()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(mnt, rel), nil
+}
+
+func locate() (mnt, rel string, err error) {
+	mounts, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return "", "", err
+	}
+	mnt, ok := ParseMountinfo(string(mounts))
+	if !ok {
+		return "", "", errors.New("cgroup v2 is not mounted")
+	}
+	own, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", "", err
+	}
+	rel, ok = ParseProcCgroup(string(own))
+	if !ok {
+		return "", "", errors.New("process is not in a cgroup v2 hierarchy")
+	}
+	return mnt, rel, nil
+}
+
+// ReadStats reads the accounting files of the cgroup at dir. It returns nil
+// if even cpu.stat, which every v2 group has, cannot be read.
+func ReadStats(dir string) *model.CgroupStats {
+	cpu, err := os.ReadFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil
+	}
+	st := &model.CgroupStats{}
+	kv := ParseKeyed(string(cpu))
+	st.CPUUsageMS = float64(kv["usage_usec"]) / 1000
+	st.CPUUserMS = float64(kv["user_usec"]) / 1000
+	st.CPUSystemMS = float64(kv["system_usec"]) / 1000
+	st.ThrottledPeriods = kv["nr_throttled"]
+	st.ThrottledMS = float64(kv["throttled_usec"]) / 1000
+
+	read := func(name string) (string, bool) {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			st.Missing = append(st.Missing, name)
+			return "", false
+		}
+		return string(data), true
+	}
+	if data, ok := read("memory.peak"); ok {
+		st.MemoryPeakBytes, _ = strconv.ParseInt(strings.TrimSpace(data), 10, 64)
+	}
+	if data, ok := read("memory.events"); ok {
+		kv := ParseKeyed(data)
+		st.MemoryHigh = kv["high"]
+		st.MemoryMax = kv["max"]
+		st.OOMKills = kv["oom_kill"]
+	}
+	if data, ok := read("io.stat"); ok {
+		io := ParseIOStat(data)
+		st.IOReadBytes, st.IOWriteBytes = io.ReadBytes, io.WriteBytes
+		st.IOReadOps, st.IOWriteOps = io.ReadOps, io.WriteOps
+	}
+	if data, ok := read("pids.peak"); ok {
+		st.PidsPeak, _ = strconv.ParseInt(strings.TrimSpace(data), 10, 64)
+	}
+	return st
+}
@@ -0,0 +1,143 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// controllers are enabled for the per-sample groups when the parent offers
+// them. Without them the groups still report cpu.stat.
+var controllers = []string{"cpu", "memory", "io", "pids"}
+
+// Setup finds the calling process's cgroup, checks that groups can be created
+// below it and enables what controllers it can for them. A group with
+// processes of its own cannot enable controllers for its children, so the
+// caller first moves into a leaf group below it. If enabling still fails, the
+// groups only report cpu.stat and their stats say why.
+func Setup() (*Parent, error) {
+	dir, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Base(dir) == leafName() {
+		// Moved there by an earlier Setup of this process.
+		dir = filepath.Dir(dir)
+	}
+	if err := syscall.Access(dir, 2 /* W_OK */); err != nil {
+		return nil, fmt.Errorf("cgroup %s is not delegated to this user: %w", dir, err)
+	}
+	// Groups of earlier runs that were kept by leftover processes can go
+	// once those have exited.
+	stale, _ := filepath.Glob(filepath.Join(dir, "why-is-this-slow-*"))
+	for _, g := range stale {
+		_ = os.Remove(g)
+	}
+
+	p := &Parent{dir: dir}
+	available, _ := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	enabled, _ := os.ReadFile(filepath.Join(dir, "cgroup.subtree_control"))
+	have := strings.Fields(string(available))
+	on := strings.Fields(string(enabled))
+	var wanted []string
+	for _, c := range controllers {
+		if contains(have, c) && !contains(on, c) {
+			wanted = append(wanted, c)
+		}
+	}
+	if len(wanted) == 0 {
+		return p, nil
+	}
+	if err := enterLeaf(dir); err != nil {
+		p.err = fmt.Errorf("move into a leaf group of %s: %w", dir, err)
+		return p, nil
+	}
+	for _, c := range wanted {
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+c), 0); err != nil {
+			// EBUSY here means other processes share the group.
+			p.err = fmt.Errorf("enable %s controller in %s: %w", c, dir, err)
+			break
+		}
+	}
+	return p, nil
+}
+
+// enterLeaf moves the calling process into a group of its own below dir,
+// unless dir holds no processes. The group is empty once the process exits
+// and goes with the stale ones on the next run.
+func enterLeaf(dir string) error {
+	procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return err
+	}
+	if len(strings.Fields(string(procs))) == 0 {
+		return nil
+	}
+	leaf := filepath.Join(dir, leafName())
+	if err := os.Mkdir(leaf, 0o755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0)
+}
+
+func leafName() string {
+	return fmt.Sprintf("why-is-this-slow-runner-%d", os.Getpid())
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NewGroup creates an empty cgroup for one sample.
+func (p *Parent) NewGroup() (*Group, error) {
+	p.seq++
+	dir := filepath.Join(p.dir, fmt.Sprintf("why-is-this-slow-%d-%d", os.Getpid(), p.seq))
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return nil, err
+	}
+	fd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		_ = os.Remove(dir)
+		return nil, err
+	}
+	return &Group{dir: dir, fd: fd, err: p.err}, nil
+}
+
+// Attach makes cmd start inside the group. The child is cloned straight into
+// it (CLONE_INTO_CGROUP), so nothing it does escapes the accounting.
+func (g *Group) Attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = g.fd
+}
+
+// Stats reads the group's accounting files.
+func (g *Group) Stats() *model.CgroupStats {
+	st := ReadStats(g.dir)
+	if st != nil && len(st.Missing) > 0 && g.err != nil {
+		st.MissingReason = g.err.Error()
+	}
+	return st
+}
+
+// Remove deletes the group. It stays behind while leftover processes that
+// were left running are still in it.
+func (g *Group) Remove() {
+	_ = syscall.Close(g.fd)
+	_ = os.Remove(g.dir)
+}
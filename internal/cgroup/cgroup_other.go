@@ -0,0 +1,20 @@
+//go:build !linux
+
+package cgroup
+
+import (
+	"os/exec"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// Setup returns ErrUnsupported: cgroups are Linux only.
+func Setup() (*Parent, error) { return nil, ErrUnsupported }
+
+func (p *Parent) NewGroup() (*Group, error) { return nil, ErrUnsupported }
+
+func (g *Group) Attach(cmd *exec.Cmd) {}
+
+func (g *Group) Stats() *model.CgroupStats { return nil }
+
+func (g *Group) Remove() {}
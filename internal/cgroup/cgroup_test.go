@@ -0,0 +1,71 @@
+package cgroup
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyed(t *testing.T) {
+	kv := ParseKeyed("usage_usec 1500\nuser_usec 1000\nsystem_usec 500\nnr_throttled 3\n")
+	if kv["usage_usec"] != 1500 || kv["system_usec"] != 500 || kv["nr_throttled"] != 3 {
+		t.Fatalf("unexpected keys: %v", kv)
+	}
+}
+
+func TestParseIOStat(t *testing.T) {
+	st := ParseIOStat("8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n259:0 rbytes=100 wbytes=0 rios=3 wios=0 dbytes=0 dios=0\n")
+	if st.ReadBytes != 4196 || st.WriteBytes != 8192 || st.ReadOps != 4 || st.WriteOps != 2 {
+		t.Fatalf("unexpected io.stat: %+v", st)
+	}
+}
+
+func TestParseMountinfoAndProcCgroup(t *testing.T) {
+	mounts := "32 1 0:27 / /sys/fs/cgroup ro,nosuid - tmpfs tmpfs ro\n" +
+		"33 32 0:28 / /sys/fs/cgroup/memory rw - cgroup cgroup rw,memory\n" +
+		"42 32 0:38 / /sys/fs/cgroup/unified rw,relatime - cgroup2 cgroup2 rw\n"
+	if mnt, ok := ParseMountinfo(mounts); !ok || mnt != "/sys/fs/cgroup/unified" {
+		t.Fatalf("mount = %q %v", mnt, ok)
+	}
+	path, ok := ParseProcCgroup("4:memory:/user.slice\n0::/user.slice/user-1000.slice/user@1000.service/app.slice\n")
+	if !ok || path != "/user.slice/user-1000.slice/user@1000.service/app.slice" {
+		t.Fatalf("path = %q %v", path, ok)
+	}
+}
+
+func TestSetupEnablesMemory(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("cgroups are Linux only")
+	}
+	p, err := Setup()
+	if err != nil {
+		t.Skipf("no delegated cgroup v2 here: %v", err)
+	}
+	available, _ := os.ReadFile(filepath.Join(p.dir, "cgroup.controllers"))
+	if !strings.Contains(string(available), "memory") {
+		t.Skip("memory controller not offered to this cgroup")
+	}
+	if p.err != nil {
+		t.Fatalf("controllers not enabled: %v", p.err)
+	}
+	g, err := p.NewGroup()
+	if err != nil {
+		t.Fatalf("new group: %v", err)
+	}
+	defer g.Remove()
+	if _, err := os.Stat(filepath.Join(g.dir, "memory.peak")); err != nil {
+		t.Skip("kernel has no memory.peak")
+	}
+	cmd := exec.Command("sh", "-c", "head -c 4000000 /dev/zero | tail -c 1 >/dev/null")
+	g.Attach(cmd)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	st := g.Stats()
+	if st == nil || st.MemoryPeakBytes == 0 || st.MissingReason != "" {
+		t.Fatalf("expected memory.peak to be filled, got %+v", st)
+	}
+}
@@ -0,0 +1,144 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// Enclosing is the cgroup the tool itself runs in, as seen from inside a
+// container. Limits are often set on an ancestor, so CPU and memory are each
+// read from the nearest group that sets a limit, or from our own group when
+// none does.
+type Enclosing struct {
+	cpuDir    string
+	memDir    string
+	quota     float64
+	memMax    int64
+	memHigh   int64
+	hasLimits bool
+}
+
+// Counters is a snapshot of the enclosing cgroup's throttling and memory
+// event counters.
+type Counters struct {
+	cpu map[string]int64
+	mem map[string]int64
+}
+
+// FindEnclosing locates the calling process's cgroup and the groups whose
+// limits apply to it.
+func FindEnclosing() (*Enclosing, error) {
+	mnt, rel, err := locate()
+	if err != nil {
+		return nil, err
+	}
+	return findEnclosing(mnt, rel), nil
+}
+
+func findEnclosing(mnt, rel string) *Enclosing {
+	own := filepath.Join(mnt, rel)
+	e := &Enclosing{cpuDir: own, memDir: own}
+	var cpuFound, memFound bool
+	for dir := own; ; dir = filepath.Dir(dir) {
+		if !cpuFound {
+			if quota, ok := ParseCPUMax(readString(filepath.Join(dir, "cpu.max"))); ok {
+				e.cpuDir, e.quota, cpuFound = dir, quota, true
+			}
+		}
+		if !memFound {
+			max, okMax := ParseLimit(readString(filepath.Join(dir, "memory.max")))
+			high, okHigh := ParseLimit(readString(filepath.Join(dir, "memory.high")))
+			if okMax || okHigh {
+				e.memDir, e.memMax, e.memHigh, memFound = dir, max, high, true
+			}
+		}
+		if dir == mnt || dir == filepath.Dir(dir) || !strings.HasPrefix(dir, mnt) {
+			break
+		}
+	}
+	e.hasLimits = cpuFound || memFound
+	return e
+}
+
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// ParseCPUMax returns the quota in cores from cpu.max ("200000 100000"). It
+// reports false for "max" or an unreadable file.
+func ParseCPUMax(data string) (float64, bool) {
+	fields := strings.Fields(data)
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, false
+	}
+	quota, err1 := strconv.ParseFloat(fields[0], 64)
+	period, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || period <= 0 {
+		return 0, false
+	}
+	return quota / period, true
+}
+
+// ParseLimit parses memory.max or memory.high. It reports false for "max" or
+// an unreadable file.
+func ParseLimit(data string) (int64, bool) {
+	n, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// CPUQuota returns the enclosing CPU quota in cores, or 0 if there is none.
+func (e *Enclosing) CPUQuota() float64 {
+	if e == nil {
+		return 0
+	}
+	return e.quota
+}
+
+// Snapshot reads the current counters. A nil Enclosing reads as nil.
+func (e *Enclosing) Snapshot() *Counters {
+	if e == nil {
+		return nil
+	}
+	return &Counters{
+		cpu: ParseKeyed(readString(filepath.Join(e.cpuDir, "cpu.stat"))),
+		mem: ParseKeyed(readString(filepath.Join(e.memDir, "memory.events"))),
+	}
+}
+
+// Limits returns the limits in force and what changed between two snapshots.
+// It returns nil when no limit is set and nothing was throttled or reclaimed,
+// which is the normal case outside containers.
+func (e *Enclosing) Limits(before, after *Counters) *model.CgroupLimits {
+	if e == nil || before == nil || after == nil {
+		return nil
+	}
+	delta := func(m, n map[string]int64, key string) int64 {
+		return max(n[key]-m[key], 0)
+	}
+	l := &model.CgroupLimits{
+		CPUQuotaCores:    e.quota,
+		MemoryMaxBytes:   e.memMax,
+		MemoryHighBytes:  e.memHigh,
+		Periods:          delta(before.cpu, after.cpu, "nr_periods"),
+		ThrottledPeriods: delta(before.cpu, after.cpu, "nr_throttled"),
+		ThrottledMS:      float64(delta(before.cpu, after.cpu, "throttled_usec")) / 1000,
+		MemoryHighEvents: delta(before.mem, after.mem, "high"),
+		MemoryMaxEvents:  delta(before.mem, after.mem, "max"),
+		OOMKills:         delta(before.mem, after.mem, "oom_kill"),
+	}
+	if !e.hasLimits && l.ThrottledPeriods == 0 && l.MemoryHighEvents == 0 && l.MemoryMaxEvents == 0 && l.OOMKills == 0 {
+		return nil
+	}
+	return l
+}
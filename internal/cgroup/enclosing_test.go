@@ -0,0 +1,47 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnclosingLimitsFromAncestors(t *testing.T) {
+	mnt := t.TempDir()
+	pod := filepath.Join(mnt, "kubepods", "pod1")
+	ctr := filepath.Join(pod, "ctr")
+	if err := os.MkdirAll(ctr, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(dir, name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(ctr, "cpu.max", "max 100000\n")
+	write(ctr, "memory.max", "max\n")
+	write(ctr, "memory.events", "low 0\nhigh 0\nmax 0\noom 0\noom_kill 0\n")
+	write(pod, "cpu.max", "150000 100000\n")
+	write(pod, "cpu.stat", "usage_usec 10\nnr_periods 10\nnr_throttled 2\nthrottled_usec 5000\n")
+
+	e := findEnclosing(mnt, "/kubepods/pod1/ctr")
+	if e.cpuDir != pod || e.quota != 1.5 || e.memDir != ctr {
+		t.Fatalf("unexpected enclosing groups: %+v", e)
+	}
+	before := e.Snapshot()
+	write(pod, "cpu.stat", "usage_usec 90\nnr_periods 30\nnr_throttled 12\nthrottled_usec 85000\n")
+	write(ctr, "memory.events", "low 0\nhigh 4\nmax 0\noom 0\noom_kill 0\n")
+	l := e.Limits(before, e.Snapshot())
+	if l == nil || l.CPUQuotaCores != 1.5 || l.Periods != 20 || l.ThrottledPeriods != 10 || l.ThrottledMS != 80 || l.MemoryHighEvents != 4 {
+		t.Fatalf("unexpected limits: %+v", l)
+	}
+}
+
+func TestEnclosingWithoutLimits(t *testing.T) {
+	mnt := t.TempDir()
+	e := findEnclosing(mnt, "/")
+	if l := e.Limits(e.Snapshot(), e.Snapshot()); l != nil {
+		t.Fatalf("expected nothing to report without limits, got %+v", l)
+	}
+}
@@ -43,20 +43,30 @@ func NewCompareCommand(st *store.Store, stdout io.Writer) *Command {
 			_ = analysisB
 
 			compAnalysis := analyze.CompareAnalysis(runA, runB)
+			syscallDeltas := analyze.SyscallDeltas(runA, runB)
+			fileDiff := analyze.DiffFileSets(runA, runB)
 
 			if *jsonOut {
 				comp := struct {
-					A model.RunResult `json:"a"`
-					B model.RunResult `json:"b"`
+					A             model.RunResult      `json:"a"`
+					B             model.RunResult      `json:"b"`
+					SyscallDeltas []model.SyscallDelta `json:"syscall_deltas,omitempty"`
+					FileDiff      *model.FileSetDiff   `json:"file_diff,omitempty"`
 				}{
-					A: runA,
-					B: runB,
+					A:             runA,
+					B:             runB,
+					SyscallDeltas: syscallDeltas,
+					FileDiff:      fileDiff,
 				}
 				if err := output.WriteJSON(stdout, comp, compAnalysis); err != nil {
 					return 1, err
 				}
 			} else {
 				output.PrintCompareSummary(stdout, runA, runB, compAnalysis)
+				output.PrintSyscallDeltas(stdout, syscallDeltas, 10)
+				if fileDiff != nil {
+					output.PrintFileSetDiff(stdout, *fileDiff, 10)
+				}
 			}
 
 			return 0, nil
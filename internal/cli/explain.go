@@ -6,16 +6,27 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/barthollomew/why-is-this-slow/internal/analyze"
 	"github.com/barthollomew/why-is-this-slow/internal/output"
 	"github.com/barthollomew/why-is-this-slow/internal/store"
 )
 
+const (
+	// treeLimit is how many executables explain --tree lists.
+	treeLimit = 15
+	// dirLimit is how many directories explain lists for --trace-files runs.
+	dirLimit = 10
+	// threadLimit is how many threads explain lists for multi-threaded runs.
+	threadLimit = 5
+)
+
 func NewExplainCommand(st *store.Store, stdout io.Writer) *Command {
 	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
 	jsonOut := fs.Bool("json", false, "output JSON")
+	tree := fs.Bool("tree", false, "show the traced process tree by executable (needs run --trace-procs)")
 
 	fs.Usage = func() {
-		fmt.Fprintf(stdout, "Usage: why-is-this-slow explain [--json] <run_id>\n")
+		fmt.Fprintf(stdout, "Usage: why-is-this-slow explain [--json] [--tree] <run_id>\n")
 		fs.PrintDefaults()
 	}
 
@@ -38,6 +49,23 @@ func NewExplainCommand(st *store.Store, stdout io.Writer) *Command {
 				}
 			} else {
 				output.PrintRunSummary(stdout, run, analysis, run.StoragePath)
+				if sample, ok := analyze.SlowestTimeline(run); ok {
+					output.PrintTimelineSummary(stdout, analyze.SummarizeTimeline(sample.Timeline), run.SampleIntervalMS)
+				}
+				if run.Threads != nil && run.Threads.Seen > 1 {
+					output.PrintThreads(stdout, *run.Threads, run.WallMS, threadLimit)
+				}
+				if run.Files != nil {
+					output.PrintFileDirs(stdout, *run.Files, analyze.FileDirs(run), dirLimit)
+				}
+				if *tree {
+					sample, ok := analyze.SlowestTree(run)
+					if !ok {
+						fmt.Fprintf(stdout, "No process tree recorded; rerun with --trace-procs\n")
+					} else {
+						output.PrintProcTree(stdout, sample.ProcTree, analyze.AggregateExecutables(sample.ProcTree), treeLimit)
+					}
+				}
 			}
 			return 0, nil
 		},
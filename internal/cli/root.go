@@ -31,6 +31,7 @@ func Execute(ctx context.Context, args []string, stdout, stderr io.Writer) int {
 		NewRunCommand(st, stdout),
 		NewExplainCommand(st, stdout),
 		NewCompareCommand(st, stdout),
+		NewSweepCommand(st, stdout),
 	}
 
 	index := map[string]*Command{}
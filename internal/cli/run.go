@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/barthollomew/why-is-this-slow/internal/analyze"
 	"github.com/barthollomew/why-is-this-slow/internal/output"
@@ -17,9 +18,35 @@ func NewRunCommand(st *store.Store, stdout io.Writer) *Command {
 	fs := flag.NewFlagSet("run", flag.ContinueOnError)
 	jsonOut := fs.Bool("json", false, "output JSON")
 	repeat := fs.Int("repeat", 1, "repeat N times and aggregate (median/p90)")
+	warmup := fs.Int("warmup", 0, "run N extra samples first and keep them out of the stats")
+	killLeftovers := fs.Bool("kill-leftovers", false, "kill descendants still running when the command exits")
+	waitLeftovers := fs.Bool("wait-leftovers", false, "wait for descendants still running when the command exits")
+	traceProcs := fs.Bool("trace-procs", false, "follow every fork/exec with ptrace and record the process tree (Linux only, adds overhead)")
+	syscalls := fs.Bool("syscalls", false, "count and time every syscall of the process tree with ptrace, like strace -c (Linux only, slow)")
+	traceNet := fs.Bool("trace-net", false, "record connect and receive wait time per remote address with ptrace (Linux only)")
+	useCgroup := fs.Bool("cgroup", false, "run each sample in a transient cgroup v2 group and record its accounting (Linux only)")
+	traceFiles := fs.Bool("trace-files", false, "record the paths the process tree opens, stats and lists with ptrace (Linux only)")
+	sampleInterval := fs.Duration("sample-interval", 0, "poll the process tree in /proc at this interval, e.g. 50ms (Linux only)")
+	sampleDetail := fs.Bool("sample-detail", false, "with --sample-interval, also count each process's open fds (Linux only, adds overhead on large trees)")
+	waitIdle := fs.Bool("wait-idle", false, "before each sample, wait until load and pressure drop below --idle-load and --idle-pressure (Linux only)")
+	idleLoad := fs.Float64("idle-load", 0.5, "with --wait-idle, the highest 1-minute load average per CPU")
+	idlePressure := fs.Float64("idle-pressure", 10, "with --wait-idle, the highest PSI some avg10 percentage of cpu, memory or io")
+	idleMaxWait := fs.Duration("idle-max-wait", time.Minute, "with --wait-idle, run the sample anyway after waiting this long")
+	cooldown := fs.Duration("cooldown", 0, "pause this long between samples, e.g. 2s")
+	timeout := fs.Duration("timeout", 0, "stop a sample that runs longer than this with SIGTERM, then SIGKILL after --timeout-grace")
+	timeoutGrace := fs.Duration("timeout-grace", 5*time.Second, "how long a timed-out sample gets to exit after SIGTERM")
+	maxTotal := fs.Duration("max-total-time", 0, "start no new samples after this long and keep the ones already taken")
+	setup := fs.String("setup", "", "shell command to run once before the first sample, not timed")
+	prepare := fs.String("prepare", "", "shell command to run before every sample, not timed")
+	cleanup := fs.String("cleanup", "", "shell command to run after every sample, not timed")
+	teardown := fs.String("teardown", "", "shell command to run once after the last sample, not timed")
+	var params, envMatrix stringList
+	fs.Var(&params, "param", "sweep a {name} placeholder in the command, e.g. threads=1,2,4 or size=range(1000,100000,*10); repeatable")
+	fs.Var(&envMatrix, "env-matrix", "sweep an environment variable, e.g. GOMAXPROCS=1,2,4; repeatable")
+	stdoutMode := fs.String("stdout", "inherit", "where the command's stdout goes: inherit, null (count and discard) or capture (keep the tail in the record)")
 
 	fs.Usage = func() {
-		fmt.Fprintf(stdout, "Usage: why-is-this-slow run [--json] [--repeat N] -- <command> [args...]\n")
+		fmt.Fprintf(stdout, "Usage: why-is-this-slow run [--json] [--repeat N] [--warmup N] [--sample-interval D] [--sample-detail] [--wait-idle] [--cooldown D] [--stdout inherit|null|capture] [--timeout D] [--max-total-time D] [--setup CMD] [--prepare CMD] [--cleanup CMD] [--teardown CMD] [--param name=values] [--env-matrix VAR=values] [--trace-procs] [--syscalls] [--trace-net] [--trace-files] [--cgroup] [--kill-leftovers|--wait-leftovers] -- <command> [args...]\n")
 		fs.PrintDefaults()
 	}
 
@@ -34,13 +61,67 @@ func NewRunCommand(st *store.Store, stdout io.Writer) *Command {
 			if *repeat < 1 {
 				return 1, fmt.Errorf("--repeat must be >=1")
 			}
+			if *warmup < 0 {
+				return 1, fmt.Errorf("--warmup must be >=0")
+			}
+			if *killLeftovers && *waitLeftovers {
+				return 1, fmt.Errorf("--kill-leftovers and --wait-leftovers are mutually exclusive")
+			}
+			leftovers := runner.LeftoversReport
+			if *killLeftovers {
+				leftovers = runner.LeftoversKill
+			} else if *waitLeftovers {
+				leftovers = runner.LeftoversWait
+			}
+			if *sampleInterval != 0 && *sampleInterval < 10*time.Millisecond {
+				return 1, fmt.Errorf("--sample-interval must be at least 10ms")
+			}
+			if *cooldown < 0 || *idleMaxWait < 0 || *idleLoad < 0 || *idlePressure < 0 {
+				return 1, fmt.Errorf("--cooldown and the --idle-* thresholds must not be negative")
+			}
+			if *timeout < 0 || *timeoutGrace < 0 || *maxTotal < 0 {
+				return 1, fmt.Errorf("--timeout, --timeout-grace and --max-total-time must not be negative")
+			}
+			mode := runner.OutputMode(*stdoutMode)
+			switch mode {
+			case runner.OutputInherit, runner.OutputNull, runner.OutputCapture:
+			default:
+				return 1, fmt.Errorf("--stdout must be inherit, null or capture")
+			}
+			var idle *runner.IdleWait
+			if *waitIdle {
+				idle = &runner.IdleWait{MaxLoad: *idleLoad, MaxPressure: *idlePressure, MaxWait: *idleMaxWait}
+			}
 
-			res, err := runner.Execute(ctx, runner.Options{
-				Command: args,
-				Repeat:  *repeat,
-			})
-			if err != nil {
-				return 1, err
+			opts := runner.Options{
+				Command:        args,
+				Repeat:         *repeat,
+				Warmup:         *warmup,
+				SampleInterval: *sampleInterval,
+				SampleDetail:   *sampleDetail,
+				Leftovers:      leftovers,
+				TraceProcs:     *traceProcs,
+				Syscalls:       *syscalls,
+				TraceNet:       *traceNet,
+				TraceFiles:     *traceFiles,
+				Cgroup:         *useCgroup,
+				WaitIdle:       idle,
+				Cooldown:       *cooldown,
+				Stdout:         mode,
+				Timeout:        *timeout,
+				KillGrace:      *timeoutGrace,
+				MaxTotalTime:   *maxTotal,
+				Hooks:          runner.Hooks{Setup: *setup, Prepare: *prepare, Cleanup: *cleanup, Teardown: *teardown},
+			}
+			if len(params) > 0 || len(envMatrix) > 0 {
+				return runSweep(ctx, st, stdout, opts, params, envMatrix, *jsonOut)
+			}
+
+			// A cancelled run comes back with the samples it took, which are
+			// saved and shown before the error.
+			res, runErr := runner.Execute(ctx, opts)
+			if runErr != nil && len(res.RawSamples) == 0 {
+				return 1, runErr
 			}
 
 			analysis := analyze.AnalyzeRun(res)
@@ -58,11 +139,26 @@ func NewRunCommand(st *store.Store, stdout io.Writer) *Command {
 				output.PrintRunSummary(stdout, res, analysis, path)
 			}
 
+			if runErr != nil {
+				return 1, runErr
+			}
 			return res.ExitCode, nil
 		},
 	}
 }
 
+// stringList collects a flag given more than once.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
 // FormatArgs rebuilds a friendly command string for display.
 func FormatArgs(args []string) string {
 	return strings.Join(args, " ")
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/analyze"
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/output"
+	"github.com/barthollomew/why-is-this-slow/internal/runner"
+	"github.com/barthollomew/why-is-this-slow/internal/store"
+	"github.com/barthollomew/why-is-this-slow/internal/sweep"
+)
+
+func NewSweepCommand(st *store.Store, stdout io.Writer) *Command {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, "output JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(stdout, "Usage: why-is-this-slow sweep [--json] <sweep_id>\n")
+		fs.PrintDefaults()
+	}
+
+	return &Command{
+		Name:    "sweep",
+		Summary: "Show a table of the runs in a recorded sweep",
+		FlagSet: fs,
+		Run: func(ctx context.Context, args []string) (int, error) {
+			if len(args) < 1 {
+				return 1, fmt.Errorf("sweep_id is required")
+			}
+			sw, err := st.LoadSweep(args[0])
+			if err != nil {
+				return 1, err
+			}
+			var records []model.Record
+			for _, id := range sw.Runs {
+				run, analysis, err := st.Load(id)
+				if err != nil {
+					return 1, err
+				}
+				records = append(records, model.Record{Version: "1.0", Run: run, Analysis: analysis})
+			}
+
+			if *jsonOut {
+				if err := output.WriteSweepJSON(stdout, sw, records); err != nil {
+					return 1, err
+				}
+			} else {
+				output.PrintSweepTable(stdout, sw, records)
+			}
+			return 0, nil
+		},
+	}
+}
+
+// runSweep runs opts once per combination of params and env, saving each as
+// its own run linked to the sweep. It returns the last non-zero exit code.
+func runSweep(ctx context.Context, st *store.Store, stdout io.Writer, opts runner.Options, paramSpecs, envSpecs []string, jsonOut bool) (int, error) {
+	sw := model.Sweep{ID: sweep.NewID(), Timestamp: time.Now().UTC(), Command: opts.Command}
+	seen := map[string]bool{}
+	for _, spec := range paramSpecs {
+		p, err := sweep.Parse(spec)
+		if err != nil {
+			return 1, fmt.Errorf("--param %w", err)
+		}
+		if seen[p.Name] {
+			return 1, fmt.Errorf("--param %s given twice", p.Name)
+		}
+		seen[p.Name] = true
+		sw.Params = append(sw.Params, p)
+	}
+	seen = map[string]bool{}
+	for _, spec := range envSpecs {
+		p, err := sweep.Parse(spec)
+		if err != nil {
+			return 1, fmt.Errorf("--env-matrix %w", err)
+		}
+		if seen[p.Name] {
+			return 1, fmt.Errorf("--env-matrix %s given twice", p.Name)
+		}
+		seen[p.Name] = true
+		sw.Env = append(sw.Env, p)
+	}
+	hooks := opts.Hooks
+	templates := append([]string{hooks.Setup, hooks.Prepare, hooks.Cleanup, hooks.Teardown}, opts.Command...)
+	if err := sweep.CheckUsed(templates, sw.Params); err != nil {
+		return 1, err
+	}
+
+	combos := sweep.Expand(sw.Params, sw.Env)
+	var records []model.Record
+	exitCode := 0
+	var runErr error
+	for i, c := range combos {
+		o := opts
+		o.Command = sweep.Apply(opts.Command, c.Params)
+		h := sweep.Apply([]string{hooks.Setup, hooks.Prepare, hooks.Cleanup, hooks.Teardown}, c.Params)
+		o.Hooks = runner.Hooks{Setup: h[0], Prepare: h[1], Cleanup: h[2], Teardown: h[3]}
+		o.Env = sweep.EnvList(c.Env)
+		res, err := runner.Execute(ctx, o)
+		if err != nil {
+			err = fmt.Errorf("sweep %s, combination %d of %d: %w", sw.ID, i+1, len(combos), err)
+			if len(res.RawSamples) == 0 {
+				return 1, err
+			}
+			// Cancelled part way: keep what it took and end the sweep.
+			runErr = err
+		}
+		res.Sweep = &model.SweepRef{ID: sw.ID, Index: i, Params: c.Params, Env: c.Env}
+
+		analysis := analyze.AnalyzeRun(res)
+		path, err := st.Save(res, analysis)
+		if err != nil {
+			return 1, err
+		}
+		res.StoragePath = path
+		sw.Runs = append(sw.Runs, res.ID)
+		// A Ctrl-C was meant for the whole sweep, not just this combination.
+		sw.Interrupted = res.Interrupted
+		if _, err := st.SaveSweep(sw); err != nil {
+			return 1, err
+		}
+		if res.ExitCode != 0 {
+			exitCode = res.ExitCode
+		}
+		records = append(records, model.Record{Version: "1.0", Run: res, Analysis: analysis})
+		if !jsonOut {
+			output.PrintSweepProgress(stdout, sw, i, len(combos), res)
+		}
+		if sw.Interrupted {
+			break
+		}
+	}
+
+	if jsonOut {
+		if err := output.WriteSweepJSON(stdout, sw, records); err != nil {
+			return 1, err
+		}
+	} else {
+		output.PrintSweepTable(stdout, sw, records)
+		fmt.Fprintf(stdout, "Sweep %s stored at: %s\n", sw.ID, st.SweepPath(sw.ID))
+	}
+	if runErr != nil {
+		return 1, runErr
+	}
+	return exitCode, nil
+}
@@ -19,3 +19,60 @@ type Record struct {
 	Run      RunResult `json:"run"`
 	Analysis Analysis  `json:"analysis"`
 }
+
+// TimelineSummary condenses a sample's timeline into peaks and idle stretches.
+type TimelineSummary struct {
+	Points         int           `json:"points"`
+	DurationMS     float64       `json:"duration_ms"`
+	PeakCPUPercent float64       `json:"peak_cpu_percent"`
+	PeakCPUAtMS    float64       `json:"peak_cpu_at_ms"`
+	PeakRSSKB      int64         `json:"peak_rss_kb"`
+	PeakRSSAtMS    float64       `json:"peak_rss_at_ms"`
+	PeakThreads    int           `json:"peak_threads"`
+	PeakOpenFDs    int           `json:"peak_open_fds"`
+	PeakProcs      int           `json:"peak_procs"`
+	IdleMS         float64       `json:"idle_ms"`
+	Idle           []IdleStretch `json:"idle,omitempty"`
+}
+
+type IdleStretch struct {
+	StartMS float64 `json:"start_ms"`
+	EndMS   float64 `json:"end_ms"`
+}
+
+// ExeStat aggregates the traced processes that ran one executable.
+type ExeStat struct {
+	Exe      string  `json:"exe"`
+	Count    int     `json:"count"`
+	WallMS   float64 `json:"wall_ms"`
+	CPUMS    float64 `json:"cpu_ms"`
+	MaxRSSKB int64   `json:"max_rss_kb"`
+}
+
+// SyscallDelta is the change in one syscall's count between two runs.
+type SyscallDelta struct {
+	Name     string  `json:"name"`
+	CountA   int64   `json:"count_a"`
+	CountB   int64   `json:"count_b"`
+	Delta    int64   `json:"delta"`
+	TotalMSA float64 `json:"total_ms_a"`
+	TotalMSB float64 `json:"total_ms_b"`
+}
+
+// DirStat rolls traced file accesses up to a top-level directory.
+type DirStat struct {
+	Dir      string  `json:"dir"`
+	Paths    int     `json:"paths"`
+	Opens    int64   `json:"opens"`
+	Stats    int64   `json:"stats"`
+	Readdirs int64   `json:"readdirs"`
+	Errors   int64   `json:"errors,omitempty"`
+	TimeMS   float64 `json:"time_ms"`
+}
+
+// FileSetDiff lists the traced paths only one of two runs touched.
+type FileSetDiff struct {
+	Common int          `json:"common"`
+	OnlyA  []FileAccess `json:"only_a,omitempty"`
+	OnlyB  []FileAccess `json:"only_b,omitempty"`
+}
@@ -3,27 +3,61 @@ package model
 import "time"
 
 type RunResult struct {
-	ID          string    `json:"id"`
-	Timestamp   time.Time `json:"timestamp"`
-	Command     []string  `json:"command"`
-	CWD         string    `json:"cwd"`
-	Platform    string    `json:"platform"`
-	WallMS      float64   `json:"wall_ms"`
-	UserMS      float64   `json:"user_ms"`
-	SysMS       float64   `json:"sys_ms"`
-	CPURatio    float64   `json:"cpu_ratio"`
-	MaxRSSRaw   int64     `json:"max_rss_raw"`
-	MaxRSSUnit  string    `json:"max_rss_unit"`
-	ExitCode    int       `json:"exit_code"`
-	Signal      string    `json:"signal,omitempty"`
-	StderrTail  string    `json:"stderr_tail,omitempty"`
-	Repeat      *Repeat   `json:"repeat,omitempty"`
-	RawSamples  []Sample  `json:"raw_samples,omitempty"`
-	StoragePath string    `json:"-"`
+	ID               string             `json:"id"`
+	Timestamp        time.Time          `json:"timestamp"`
+	Command          []string           `json:"command"`
+	CWD              string             `json:"cwd"`
+	Platform         string             `json:"platform"`
+	CPUs             *CPUCount          `json:"cpus,omitempty"`
+	WallMS           float64            `json:"wall_ms"`
+	UserMS           float64            `json:"user_ms"`
+	SysMS            float64            `json:"sys_ms"`
+	CPURatio         float64            `json:"cpu_ratio"`
+	MaxRSSRaw        int64              `json:"max_rss_raw"`
+	MaxRSSUnit       string             `json:"max_rss_unit"`
+	MinorFaults      int64              `json:"minor_faults"`
+	MajorFaults      int64              `json:"major_faults"`
+	InBlock          int64              `json:"in_block"`
+	OutBlock         int64              `json:"out_block"`
+	VolCtxSw         int64              `json:"voluntary_ctx_switches"`
+	InvolCtxSw       int64              `json:"involuntary_ctx_switches"`
+	RunDelayMS       float64            `json:"run_delay_ms,omitempty"`
+	WaitStates       *WaitStates        `json:"wait_states,omitempty"`
+	IO               *IOCounters        `json:"io,omitempty"`
+	Perf             *PerfCounters      `json:"perf,omitempty"`
+	Cgroup           *CgroupStats       `json:"cgroup,omitempty"`
+	Limits           *CgroupLimits      `json:"cgroup_limits,omitempty"`
+	Pressure         *Pressure          `json:"pressure,omitempty"`
+	Memory           *MemoryComposition `json:"memory,omitempty"`
+	Threads          *ThreadStats       `json:"threads,omitempty"`
+	Environment      *Environment       `json:"environment,omitempty"`
+	Idle             *IdleWait          `json:"idle,omitempty"`
+	Hooks            []HookRun          `json:"hooks,omitempty"`
+	Sweep            *SweepRef          `json:"sweep,omitempty"`
+	TimeoutMS        float64            `json:"timeout_ms,omitempty"`
+	TimedOut         int                `json:"timed_out,omitempty"`
+	Interrupted      bool               `json:"interrupted,omitempty"`
+	Leftovers        []LeftoverProcess  `json:"leftovers,omitempty"`
+	LeftoverPolicy   string             `json:"leftover_policy,omitempty"`
+	Syscalls         []SyscallStat      `json:"syscalls,omitempty"`
+	Network          []NetDest          `json:"network,omitempty"`
+	Files            *FileTrace         `json:"files,omitempty"`
+	ExitCode         int                `json:"exit_code"`
+	Signal           string             `json:"signal,omitempty"`
+	StderrTail       string             `json:"stderr_tail,omitempty"`
+	StdoutTail       string             `json:"stdout_tail,omitempty"`
+	Output           *Output            `json:"output,omitempty"`
+	SampleIntervalMS float64            `json:"sample_interval_ms,omitempty"`
+	Repeat           *Repeat            `json:"repeat,omitempty"`
+	RawSamples       []Sample           `json:"raw_samples,omitempty"`
+	Warmup           []Sample           `json:"warmup,omitempty"`
+	StoragePath      string             `json:"-"`
 }
 
 type Repeat struct {
 	Count          int      `json:"count"`
+	Requested      int      `json:"requested"`
+	MaxTotalMS     float64  `json:"max_total_ms,omitempty"`
 	MedianWallMS   float64  `json:"median_wall_ms"`
 	P90WallMS      float64  `json:"p90_wall_ms"`
 	MedianCPURatio float64  `json:"median_cpu_ratio"`
@@ -31,12 +65,335 @@ type Repeat struct {
 }
 
 type Sample struct {
-	WallMS     float64 `json:"wall_ms"`
-	UserMS     float64 `json:"user_ms"`
-	SysMS      float64 `json:"sys_ms"`
-	CPURatio   float64 `json:"cpu_ratio"`
-	MaxRSS     int64   `json:"max_rss_raw"`
-	MaxRSSUnit string  `json:"max_rss_unit,omitempty"`
-	ExitCode   int     `json:"exit_code"`
-	Signal     string  `json:"signal,omitempty"`
+	WallMS      float64            `json:"wall_ms"`
+	UserMS      float64            `json:"user_ms"`
+	SysMS       float64            `json:"sys_ms"`
+	CPURatio    float64            `json:"cpu_ratio"`
+	MaxRSS      int64              `json:"max_rss_raw"`
+	MaxRSSUnit  string             `json:"max_rss_unit,omitempty"`
+	MinorFaults int64              `json:"minor_faults"`
+	MajorFaults int64              `json:"major_faults"`
+	InBlock     int64              `json:"in_block"`
+	OutBlock    int64              `json:"out_block"`
+	VolCtxSw    int64              `json:"voluntary_ctx_switches"`
+	InvolCtxSw  int64              `json:"involuntary_ctx_switches"`
+	RunDelayMS  float64            `json:"run_delay_ms,omitempty"`
+	WaitStates  *WaitStates        `json:"wait_states,omitempty"`
+	IO          *IOCounters        `json:"io,omitempty"`
+	Perf        *PerfCounters      `json:"perf,omitempty"`
+	Cgroup      *CgroupStats       `json:"cgroup,omitempty"`
+	Limits      *CgroupLimits      `json:"cgroup_limits,omitempty"`
+	Pressure    *Pressure          `json:"pressure,omitempty"`
+	Memory      *MemoryComposition `json:"memory,omitempty"`
+	Threads     *ThreadStats       `json:"threads,omitempty"`
+	Output      *Output            `json:"output,omitempty"`
+	IdleWaitMS  float64            `json:"idle_wait_ms,omitempty"`
+	IdleTimeout bool               `json:"idle_timeout,omitempty"`
+	Hooks       []HookRun          `json:"hooks,omitempty"`
+	TimedOut    bool               `json:"timed_out,omitempty"`
+	Interrupted bool               `json:"interrupted,omitempty"`
+	Forwarded   []string           `json:"forwarded_signals,omitempty"`
+	Leftovers   []LeftoverProcess  `json:"leftovers,omitempty"`
+	ProcTree    []ProcNode         `json:"proc_tree,omitempty"`
+	Syscalls    []SyscallStat      `json:"syscalls,omitempty"`
+	Network     []NetDest          `json:"network,omitempty"`
+	Files       *FileTrace         `json:"files,omitempty"`
+	ExitCode    int                `json:"exit_code"`
+	Signal      string             `json:"signal,omitempty"`
+	Timeline    []TimelinePoint    `json:"timeline,omitempty"`
+}
+
+// CutShort reports whether the sample was stopped before the command
+// finished, by a timeout or a signal. Its timings are not the command's.
+func (s Sample) CutShort() bool {
+	return s.TimedOut || s.Interrupted
+}
+
+// TimelinePoint is one poll of the command's process tree while it runs.
+// Values are summed over the child and all of its descendants.
+type TimelinePoint struct {
+	OffsetMS   float64 `json:"offset_ms"`
+	CPUPercent float64 `json:"cpu_percent"`
+	RSSKB      int64   `json:"rss_kb"`
+	Threads    int     `json:"threads"`
+	OpenFDs    int     `json:"open_fds"`
+	Procs      int     `json:"procs"`
+}
+
+// WaitStates is what the sampler saw while the command was not on a CPU.
+// Each poll counts once, by the busiest state of any thread in the tree:
+// running beats disk (D) which beats sleeping (S).
+type WaitStates struct {
+	BlkioDelayMS float64        `json:"blkio_delay_ms,omitempty"`
+	RunningPolls int            `json:"running_polls"`
+	DiskPolls    int            `json:"disk_polls"`
+	SleepPolls   int            `json:"sleep_polls"`
+	Wchan        map[string]int `json:"wchan,omitempty"`
+}
+
+// IOCounters come from /proc/<pid>/io, read before the child is reaped.
+// Chars count every read/write call including page-cache hits; Bytes count
+// what reached or came from storage.
+type IOCounters struct {
+	ReadChars  int64 `json:"rchar"`
+	WriteChars int64 `json:"wchar"`
+	ReadCalls  int64 `json:"syscr"`
+	WriteCalls int64 `json:"syscw"`
+	ReadBytes  int64 `json:"read_bytes"`
+	WriteBytes int64 `json:"write_bytes"`
+}
+
+// PerfCounters come from perf_event_open counters inherited by the child and
+// its descendants. The hardware counts are zero when the kernel or hypervisor
+// does not expose them; Unavailable names the events that could not be opened.
+type PerfCounters struct {
+	TaskClockMS     float64  `json:"task_clock_ms"`
+	ContextSwitches int64    `json:"context_switches"`
+	CPUMigrations   int64    `json:"cpu_migrations"`
+	PageFaults      int64    `json:"page_faults"`
+	Cycles          int64    `json:"cycles,omitempty"`
+	Instructions    int64    `json:"instructions,omitempty"`
+	CacheMisses     int64    `json:"cache_misses,omitempty"`
+	IPC             float64  `json:"ipc,omitempty"`
+	UserOnly        bool     `json:"user_only,omitempty"`
+	Multiplexed     bool     `json:"multiplexed,omitempty"`
+	Unavailable     []string `json:"unavailable,omitempty"`
+}
+
+// CgroupStats come from the transient cgroup v2 a sample ran in. Unlike
+// rusage they cover every process in the tree, waited for or not. Fields whose
+// controller was not available are zero and their files listed in Missing,
+// with MissingReason saying why when the controller could not be enabled.
+type CgroupStats struct {
+	CPUUsageMS       float64  `json:"cpu_usage_ms"`
+	CPUUserMS        float64  `json:"cpu_user_ms"`
+	CPUSystemMS      float64  `json:"cpu_system_ms"`
+	ThrottledPeriods int64    `json:"throttled_periods,omitempty"`
+	ThrottledMS      float64  `json:"throttled_ms,omitempty"`
+	MemoryPeakBytes  int64    `json:"memory_peak_bytes,omitempty"`
+	MemoryHigh       int64    `json:"memory_high_events,omitempty"`
+	MemoryMax        int64    `json:"memory_max_events,omitempty"`
+	OOMKills         int64    `json:"oom_kills,omitempty"`
+	IOReadBytes      int64    `json:"io_read_bytes,omitempty"`
+	IOWriteBytes     int64    `json:"io_write_bytes,omitempty"`
+	IOReadOps        int64    `json:"io_read_ops,omitempty"`
+	IOWriteOps       int64    `json:"io_write_ops,omitempty"`
+	PidsPeak         int64    `json:"pids_peak,omitempty"`
+	Missing          []string `json:"missing,omitempty"`
+	MissingReason    string   `json:"missing_reason,omitempty"`
+}
+
+// CgroupLimits are the CPU and memory limits of the cgroup the tool runs in
+// (usually a container's) and the throttling and reclaim they caused during a
+// sample, from snapshots of cpu.stat and memory.events before and after.
+type CgroupLimits struct {
+	CPUQuotaCores    float64 `json:"cpu_quota_cores,omitempty"`
+	MemoryMaxBytes   int64   `json:"memory_max_bytes,omitempty"`
+	MemoryHighBytes  int64   `json:"memory_high_bytes,omitempty"`
+	Periods          int64   `json:"periods,omitempty"`
+	ThrottledPeriods int64   `json:"throttled_periods,omitempty"`
+	ThrottledMS      float64 `json:"throttled_ms,omitempty"`
+	MemoryHighEvents int64   `json:"memory_high_events,omitempty"`
+	MemoryMaxEvents  int64   `json:"memory_max_events,omitempty"`
+	OOMKills         int64   `json:"oom_kills,omitempty"`
+}
+
+// MemoryComposition splits the process tree's memory at its peak, summed
+// over every process's smaps_rollup. Sizes are proportional (PSS), so pages
+// shared within the tree count once. SwapKB is the most that was swapped out
+// at any poll.
+type MemoryComposition struct {
+	PeakKB  int64   `json:"peak_kb"`
+	AnonKB  int64   `json:"anon_kb"`
+	FileKB  int64   `json:"file_kb"`
+	ShmemKB int64   `json:"shmem_kb"`
+	SwapKB  int64   `json:"swap_kb"`
+	AtMS    float64 `json:"at_ms"`
+}
+
+// Output is what the command wrote to stdout and stderr. Both are relayed,
+// stdout through a pty when it is a terminal (Linux) and pipes otherwise;
+// BlockedMS is how long writing on to our own stdout or stderr blocked,
+// during which the command stalls once the pipe or pty is full. Mode is the
+// --stdout setting and Terminal whether stdout was a terminal.
+type Output struct {
+	Mode     string      `json:"mode"`
+	Terminal bool        `json:"terminal,omitempty"`
+	Stdout   StreamStats `json:"stdout"`
+	Stderr   StreamStats `json:"stderr"`
+}
+
+// StreamStats counts one output stream.
+type StreamStats struct {
+	Bytes     int64   `json:"bytes"`
+	Lines     int64   `json:"lines"`
+	BlockedMS float64 `json:"blocked_ms"`
+}
+
+// ThreadStats attributes a sample's CPU time to threads, from the last
+// /proc/<pid>/task/<tid>/stat the sampler read for each. Threads that started
+// and exited between two polls are missed. Busy counts threads on CPU for at
+// least 5% of wall; Top holds the busiest.
+type ThreadStats struct {
+	Seen  int         `json:"seen"`
+	Peak  int         `json:"peak"`
+	Busy  int         `json:"busy"`
+	CPUMS float64     `json:"cpu_ms"`
+	Top   []ThreadCPU `json:"top"`
+}
+
+// ThreadCPU is one thread's CPU time; Comm is the thread's own name.
+type ThreadCPU struct {
+	PID   int     `json:"pid"`
+	TID   int     `json:"tid"`
+	Comm  string  `json:"comm"`
+	CPUMS float64 `json:"cpu_ms"`
+}
+
+// Pressure is how long the whole machine was stalled during a sample, from
+// the "total" counters in /proc/pressure before and after. Some means at
+// least one task was waiting on the resource, full that every non-idle task
+// was. The command's own stalls are included.
+type Pressure struct {
+	CPUSomeMS    float64 `json:"cpu_some_ms"`
+	MemorySomeMS float64 `json:"memory_some_ms"`
+	MemoryFullMS float64 `json:"memory_full_ms"`
+	IOSomeMS     float64 `json:"io_some_ms"`
+	IOFullMS     float64 `json:"io_full_ms"`
+}
+
+// Environment describes how quiet the machine was around a run, from
+// snapshots taken before the first sample and after the last. Quality is a
+// 0-100 score of how far the numbers can be trusted; Issues says what cost
+// points.
+type Environment struct {
+	LoadStart     float64       `json:"load_start"`
+	LoadEnd       float64       `json:"load_end"`
+	Governor      string        `json:"governor,omitempty"`
+	FreqCapped    bool          `json:"freq_capped,omitempty"`
+	StealPercent  float64       `json:"steal_percent"`
+	IOWaitPercent float64       `json:"iowait_percent"`
+	BusyProcs     []BusyProcess `json:"busy_procs,omitempty"`
+	Quality       int           `json:"quality"`
+	Issues        []string      `json:"issues,omitempty"`
+}
+
+// IdleWait records --wait-idle and --cooldown: the thresholds used, the
+// total time spent waiting for a quiet machine before samples, and how many
+// of those waits gave up at the limit.
+type IdleWait struct {
+	MaxLoad     float64 `json:"max_load,omitempty"`
+	MaxPressure float64 `json:"max_pressure,omitempty"`
+	MaxWaitMS   float64 `json:"max_wait_ms,omitempty"`
+	CooldownMS  float64 `json:"cooldown_ms,omitempty"`
+	WaitedMS    float64 `json:"waited_ms"`
+	TimedOut    int     `json:"timed_out,omitempty"`
+}
+
+// HookRun is one --setup, --prepare, --cleanup or --teardown command. Hooks
+// run outside the timed region.
+type HookRun struct {
+	Kind     string  `json:"kind"`
+	Command  string  `json:"command"`
+	WallMS   float64 `json:"wall_ms"`
+	ExitCode int     `json:"exit_code"`
+}
+
+// BusyProcess is another process that used CPU while the run was measured.
+type BusyProcess struct {
+	PID        int     `json:"pid"`
+	Comm       string  `json:"comm"`
+	CPUPercent float64 `json:"cpu_percent"`
+}
+
+// CPUCount is how many CPUs the command could use: the most limiting of the
+// online CPUs, the scheduler affinity mask and the enclosing cgroup's quota.
+// Limit names which one that was.
+type CPUCount struct {
+	Online    int     `json:"online"`
+	Affinity  int     `json:"affinity,omitempty"`
+	Quota     float64 `json:"quota,omitempty"`
+	Available float64 `json:"available"`
+	Limit     string  `json:"limit"`
+}
+
+// LeftoverProcess is a descendant that was still around when the direct
+// child exited. LifetimeMS is its age at that moment. State is one of
+// running, exited, killed, or waited.
+type LeftoverProcess struct {
+	PID        int     `json:"pid"`
+	PPID       int     `json:"ppid"`
+	Comm       string  `json:"comm"`
+	LifetimeMS float64 `json:"lifetime_ms"`
+	State      string  `json:"state"`
+}
+
+// ProcNode is one process seen by the ptrace tracer. The tree is stored flat;
+// PPID links each node to its parent. Times are offsets from the sample start.
+type ProcNode struct {
+	PID      int      `json:"pid"`
+	PPID     int      `json:"ppid"`
+	Exe      string   `json:"exe"`
+	Argv     []string `json:"argv,omitempty"`
+	StartMS  float64  `json:"start_ms"`
+	EndMS    float64  `json:"end_ms"`
+	UserMS   float64  `json:"user_ms"`
+	SysMS    float64  `json:"sys_ms"`
+	MaxRSSKB int64    `json:"max_rss_kb"`
+	ExitCode int      `json:"exit_code"`
+	Signal   string   `json:"signal,omitempty"`
+	Detached bool     `json:"detached,omitempty"`
+}
+
+// SyscallStat summarises one syscall across a traced process tree. Times are
+// wall time between syscall entry and exit as seen by the tracer. Timeouts
+// counts poll-style calls that returned without an event.
+type SyscallStat struct {
+	Name      string  `json:"name"`
+	Count     int64   `json:"count"`
+	Errors    int64   `json:"errors"`
+	TotalMS   float64 `json:"total_ms"`
+	MaxMS     float64 `json:"max_ms"`
+	Timeouts  int64   `json:"timeouts,omitempty"`
+	TimeoutMS float64 `json:"timeout_ms,omitempty"`
+}
+
+// NetDest is one remote endpoint the traced tree talked to. ConnectMS is the
+// time from connect() until the connection was usable; RecvBlockedMS is time
+// spent waiting for data in read/recv on sockets connected to it.
+type NetDest struct {
+	Address       string  `json:"address"`
+	Proto         string  `json:"proto"`
+	Connects      int64   `json:"connects"`
+	ConnectErrors int64   `json:"connect_errors"`
+	ConnectMS     float64 `json:"connect_ms"`
+	MaxConnectMS  float64 `json:"max_connect_ms"`
+	Reads         int64   `json:"reads"`
+	RecvBlockedMS float64 `json:"recv_blocked_ms"`
+	BytesIn       int64   `json:"bytes_in"`
+	BytesOut      int64   `json:"bytes_out"`
+}
+
+// FileTrace is what --trace-files saw. Paths counts distinct paths; Top holds
+// them ordered by time spent, capped to keep records small.
+type FileTrace struct {
+	Paths    int          `json:"paths"`
+	Opens    int64        `json:"opens"`
+	Stats    int64        `json:"stats"`
+	Readdirs int64        `json:"readdirs"`
+	Errors   int64        `json:"errors"`
+	OpenMS   float64      `json:"open_ms"`
+	MetaMS   float64      `json:"meta_ms"`
+	Top      []FileAccess `json:"top"`
+}
+
+// FileAccess counts the calls made on one path. Stats covers stat, access and
+// readlink style lookups; Errors counts calls that failed, mostly ENOENT.
+type FileAccess struct {
+	Path     string  `json:"path"`
+	Opens    int64   `json:"opens,omitempty"`
+	Stats    int64   `json:"stats,omitempty"`
+	Readdirs int64   `json:"readdirs,omitempty"`
+	Errors   int64   `json:"errors,omitempty"`
+	TimeMS   float64 `json:"time_ms"`
 }
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Sweep groups the runs of one `run --param`/`--env-matrix` invocation. Each
+// combination is stored as its own run, listed in Runs in the order it ran.
+// Interrupted is set when a forwarded signal stopped the sweep early.
+type Sweep struct {
+	ID          string       `json:"id"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Command     []string     `json:"command"`
+	Params      []SweepParam `json:"params,omitempty"`
+	Env         []SweepParam `json:"env,omitempty"`
+	Runs        []string     `json:"runs"`
+	Interrupted bool         `json:"interrupted,omitempty"`
+}
+
+// SweepParam is one --param or --env-matrix axis with its expanded values.
+type SweepParam struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// SweepRef links a run to its sweep and names the combination it measured.
+type SweepRef struct {
+	ID     string            `json:"id"`
+	Index  int               `json:"index"`
+	Params map[string]string `json:"params,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+}
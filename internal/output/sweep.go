@@ -0,0 +1,100 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// SweepRecord is the JSON form of a sweep with its runs.
+type SweepRecord struct {
+	Version string         `json:"version"`
+	Sweep   model.Sweep    `json:"sweep"`
+	Runs    []model.Record `json:"runs"`
+}
+
+func WriteSweepJSON(out io.Writer, sw model.Sweep, runs []model.Record) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(SweepRecord{Version: "1.0", Sweep: sw, Runs: runs})
+}
+
+// PrintSweepProgress prints one line after each combination of a sweep.
+func PrintSweepProgress(out io.Writer, sw model.Sweep, i, total int, run model.RunResult) {
+	fmt.Fprintf(out, "[%d/%d] %s: wall %.1fms cpu_ratio %.2f exit %d (%s)\n", i+1, total, sweepLabel(sw, run.Sweep), run.WallMS, run.CPURatio, run.ExitCode, run.ID)
+}
+
+// PrintSweepTable prints one row per combination, with a column per param
+// and env-matrix variable.
+func PrintSweepTable(out io.Writer, sw model.Sweep, runs []model.Record) {
+	fmt.Fprintf(out, "Sweep: %s\n", sw.ID)
+	fmt.Fprintf(out, "Command: %s\n", strings.Join(sw.Command, " "))
+
+	axes := append(append([]model.SweepParam(nil), sw.Params...), sw.Env...)
+	widths := make([]int, len(axes))
+	for i, a := range axes {
+		widths[i] = len(a.Name)
+		for _, v := range a.Values {
+			widths[i] = max(widths[i], len(v))
+		}
+	}
+	fmt.Fprint(out, " ")
+	for i, a := range axes {
+		fmt.Fprintf(out, " %-*s", widths[i], a.Name)
+	}
+	fmt.Fprintf(out, " %10s %10s %6s %14s %4s  %s\n", "WALL", "P90", "CPU", "MAX RSS", "EXIT", "RUN")
+	for _, rec := range runs {
+		run := rec.Run
+		fmt.Fprint(out, " ")
+		for i, a := range axes {
+			fmt.Fprintf(out, " %-*s", widths[i], axisValue(run.Sweep, a.Name, i >= len(sw.Params)))
+		}
+		p90 := run.WallMS
+		if run.Repeat != nil {
+			p90 = run.Repeat.P90WallMS
+		}
+		rss := fmt.Sprintf("%d %s", run.MaxRSSRaw, safeUnit(run.MaxRSSUnit))
+		fmt.Fprintf(out, " %8.1fms %8.1fms %6.2f %14s %4d  %s\n", run.WallMS, p90, run.CPURatio, rss, run.ExitCode, run.ID)
+	}
+	if sw.Interrupted {
+		fmt.Fprintf(out, "Interrupted after %d of %d combinations\n", len(runs), sweepSize(sw))
+	}
+}
+
+// sweepSize is the number of combinations the sweep's axes expand to.
+func sweepSize(sw model.Sweep) int {
+	n := 1
+	for _, a := range append(append([]model.SweepParam(nil), sw.Params...), sw.Env...) {
+		n *= len(a.Values)
+	}
+	return n
+}
+
+// sweepLabel names a run's combination in the sweep's axis order.
+func sweepLabel(sw model.Sweep, ref *model.SweepRef) string {
+	var parts []string
+	for _, p := range sw.Params {
+		parts = append(parts, p.Name+"="+axisValue(ref, p.Name, false))
+	}
+	for _, p := range sw.Env {
+		parts = append(parts, p.Name+"="+axisValue(ref, p.Name, true))
+	}
+	return strings.Join(parts, " ")
+}
+
+func axisValue(ref *model.SweepRef, name string, env bool) string {
+	if ref == nil {
+		return "-"
+	}
+	vals := ref.Params
+	if env {
+		vals = ref.Env
+	}
+	if v, ok := vals[name]; ok {
+		return v
+	}
+	return "-"
+}
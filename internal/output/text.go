@@ -15,19 +15,112 @@ func PrintRunSummary(out io.Writer, run model.RunResult, analysis model.Analysis
 	}
 
 	if run.Repeat != nil && run.Repeat.Count > 1 {
-		fmt.Fprintf(out, "Wall: median %.1fms p90 %.1fms (n=%d)\n", run.Repeat.MedianWallMS, run.Repeat.P90WallMS, run.Repeat.Count)
+		n := run.Repeat.Count
+		if cut := len(cutSummary(run.RawSamples)); cut < len(run.RawSamples) {
+			n -= cut
+		}
+		fmt.Fprintf(out, "Wall: median %.1fms p90 %.1fms (n=%d)\n", run.Repeat.MedianWallMS, run.Repeat.P90WallMS, n)
 	} else {
 		fmt.Fprintf(out, "Wall: %.1fms\n", run.WallMS)
 	}
+	if run.Interrupted {
+		fmt.Fprintf(out, "Interrupted: %d samples kept%s\n", len(run.RawSamples), forwardedSummary(run.RawSamples))
+	} else if r := run.Repeat; r != nil && r.Count < r.Requested {
+		fmt.Fprintf(out, "Stopped early: %d of %d samples within --max-total-time %.1fs\n", r.Count, r.Requested, r.MaxTotalMS/1000)
+	}
+	if run.TimedOut > 0 {
+		excluded := ", excluded from stats"
+		if run.TimedOut >= len(run.RawSamples) {
+			excluded = ", stats are a lower bound"
+		}
+		fmt.Fprintf(out, "Timed out: %d of %d samples stopped after %.1fs%s\n", run.TimedOut, max(len(run.RawSamples), 1), run.TimeoutMS/1000, excluded)
+	}
+	if cut := cutSummary(run.RawSamples); len(cut) > 0 && len(cut) < len(run.RawSamples) {
+		fmt.Fprintf(out, "Cut short, not in stats: %s\n", strings.Join(cut, ", "))
+	}
 
-	fmt.Fprintf(out, "CPU: user %.1fms sys %.1fms cpu_ratio %.2f\n", run.UserMS, run.SysMS, run.CPURatio)
+	fmt.Fprintf(out, "CPU: user %.1fms sys %.1fms cpu_ratio %.2f", run.UserMS, run.SysMS, run.CPURatio)
+	if c := run.CPUs; c != nil && c.Available > 0 {
+		fmt.Fprintf(out, " (%.0f%% of %g CPUs, limited by %s)", run.CPURatio/c.Available*100, c.Available, c.Limit)
+	}
+	fmt.Fprintln(out)
+	if run.RunDelayMS > 0 {
+		fmt.Fprintf(out, "Run-queue delay: %.1fms\n", run.RunDelayMS)
+	}
 	fmt.Fprintf(out, "Max RSS: %d %s (%s)\n", run.MaxRSSRaw, safeUnit(run.MaxRSSUnit), run.Platform)
+	if m := run.Memory; m != nil {
+		fmt.Fprintf(out, "Memory at peak: %.1f MB = anon %.1f MB + file %.1f MB + shmem %.1f MB, swap %.1f MB\n",
+			float64(m.PeakKB)/1024, float64(m.AnonKB)/1024, float64(m.FileKB)/1024, float64(m.ShmemKB)/1024, float64(m.SwapKB)/1024)
+	}
+	fmt.Fprintf(out, "Faults: minor %d major %d, block I/O: in %d out %d\n", run.MinorFaults, run.MajorFaults, run.InBlock, run.OutBlock)
+	fmt.Fprintf(out, "Context switches: voluntary %d involuntary %d\n", run.VolCtxSw, run.InvolCtxSw)
+	if run.IO != nil {
+		fmt.Fprintf(out, "I/O: read %.1f MB write %.1f MB storage, %d read / %d write calls\n",
+			float64(run.IO.ReadBytes)/(1<<20), float64(run.IO.WriteBytes)/(1<<20), run.IO.ReadCalls, run.IO.WriteCalls)
+	}
+	if run.Perf != nil {
+		printPerf(out, run.Perf)
+	}
+	if run.Cgroup != nil {
+		printCgroup(out, run.Cgroup)
+	}
+	if run.Limits != nil {
+		printLimits(out, run.Limits)
+	}
+	if p := run.Pressure; p != nil {
+		fmt.Fprintf(out, "System pressure: cpu %.1fms, memory %.1fms (full %.1fms), io %.1fms (full %.1fms)\n", p.CPUSomeMS, p.MemorySomeMS, p.MemoryFullMS, p.IOSomeMS, p.IOFullMS)
+	}
+	if o := run.Output; o != nil && o.Stdout.Bytes+o.Stderr.Bytes > 0 {
+		fmt.Fprintf(out, "Output (%s): stdout %.1f MB %d lines, stderr %.1f MB %d lines, blocked %.1fms\n", o.Mode,
+			float64(o.Stdout.Bytes)/(1<<20), o.Stdout.Lines, float64(o.Stderr.Bytes)/(1<<20), o.Stderr.Lines, o.Stdout.BlockedMS+o.Stderr.BlockedMS)
+	}
+	if len(run.Leftovers) > 0 {
+		fmt.Fprintf(out, "Leftover processes (%s):\n", run.LeftoverPolicy)
+		for i, p := range run.Leftovers {
+			if i >= 5 {
+				fmt.Fprintf(out, "  ... %d more\n", len(run.Leftovers)-i)
+				break
+			}
+			fmt.Fprintf(out, "  pid %d %s %s after %.1fms\n", p.PID, p.Comm, p.State, p.LifetimeMS)
+		}
+	}
+	if len(run.Syscalls) > 0 {
+		printSyscalls(out, run.Syscalls, 5)
+	}
+	if len(run.Network) > 0 {
+		printNetwork(out, run.Network, 5)
+	}
 	fmt.Fprintf(out, "Exit: code=%d", run.ExitCode)
 	if run.Signal != "" {
 		fmt.Fprintf(out, " signal=%s", run.Signal)
 	}
 	fmt.Fprint(out, "\n")
 
+	if env := run.Environment; env != nil {
+		fmt.Fprintf(out, "Measurement quality: %d/100 (load %.2f -> %.2f, steal %.1f%%, iowait %.1f%%)\n", env.Quality, env.LoadStart, env.LoadEnd, env.StealPercent, env.IOWaitPercent)
+	}
+	if line := hookSummary(run); line != "" {
+		fmt.Fprintf(out, "Hooks (not timed): %s\n", line)
+	}
+	if len(run.Warmup) > 0 {
+		fmt.Fprintf(out, "Warmup: %d samples excluded from stats, first %.1fms\n", len(run.Warmup), run.Warmup[0].WallMS)
+	}
+	if idle := run.Idle; idle != nil {
+		fmt.Fprintf(out, "Idle wait: %.1fs before samples", idle.WaitedMS/1000)
+		if idle.TimedOut > 0 {
+			fmt.Fprintf(out, ", %d gave up after %.0fs", idle.TimedOut, idle.MaxWaitMS/1000)
+		}
+		if idle.CooldownMS > 0 {
+			fmt.Fprintf(out, ", cooldown %.1fs", idle.CooldownMS/1000)
+		}
+		fmt.Fprintln(out)
+	}
+	for _, e := range analysis.Explanations {
+		if e.ID == "NOISY_ENVIRONMENT" {
+			fmt.Fprintf(out, "Warning: %s - %s\n", e.ID, e.Message)
+		}
+	}
+
 	top := pickTopExplanation(analysis.Explanations)
 	fmt.Fprintf(out, "Classification: %s\n", analysis.Classification)
 	fmt.Fprintf(out, "Top insight: %s - %s\n", top.ID, top.Message)
@@ -46,12 +139,206 @@ func PrintRunSummary(out io.Writer, run model.RunResult, analysis model.Analysis
 	}
 }
 
+// PrintTimelineSummary prints peaks and idle stretches of a sampled timeline.
+func PrintTimelineSummary(out io.Writer, sum model.TimelineSummary, intervalMS float64) {
+	fmt.Fprintf(out, "Timeline: %d points every %.0fms over %.1fms\n", sum.Points, intervalMS, sum.DurationMS)
+	fmt.Fprintf(out, "  Peak CPU: %.0f%% at +%.1fms\n", sum.PeakCPUPercent, sum.PeakCPUAtMS)
+	fmt.Fprintf(out, "  Peak RSS: %d kB at +%.1fms\n", sum.PeakRSSKB, sum.PeakRSSAtMS)
+	if sum.PeakOpenFDs > 0 {
+		fmt.Fprintf(out, "  Peak threads %d, open fds %d, processes %d\n", sum.PeakThreads, sum.PeakOpenFDs, sum.PeakProcs)
+	} else {
+		fmt.Fprintf(out, "  Peak threads %d, processes %d\n", sum.PeakThreads, sum.PeakProcs)
+	}
+	if len(sum.Idle) == 0 {
+		fmt.Fprintf(out, "  Idle: none\n")
+		return
+	}
+	fmt.Fprintf(out, "  Idle: %.1fms in %d stretches\n", sum.IdleMS, len(sum.Idle))
+	for i, st := range sum.Idle {
+		if i >= 5 {
+			fmt.Fprintf(out, "    ... %d more\n", len(sum.Idle)-i)
+			break
+		}
+		fmt.Fprintf(out, "    +%.1fms to +%.1fms\n", st.StartMS, st.EndMS)
+	}
+}
+
+// PrintProcTree prints the traced process tree aggregated by executable.
+func PrintProcTree(out io.Writer, nodes []model.ProcNode, byExe []model.ExeStat, limit int) {
+	fmt.Fprintf(out, "Process tree: %d processes, %d executables\n", len(nodes), len(byExe))
+	fmt.Fprintf(out, "  %-24s %6s %12s %12s %10s\n", "EXE", "COUNT", "WALL", "CPU", "MAX RSS")
+	for i, st := range byExe {
+		if i >= limit {
+			fmt.Fprintf(out, "  ... %d more\n", len(byExe)-i)
+			break
+		}
+		fmt.Fprintf(out, "  %-24s %6d %10.1fms %10.1fms %7d kB\n", st.Exe, st.Count, st.WallMS, st.CPUMS, st.MaxRSSKB)
+	}
+}
+
+func printPerf(out io.Writer, p *model.PerfCounters) {
+	fmt.Fprintf(out, "Perf: task-clock %.1fms, %d context switches, %d migrations, %d page faults", p.TaskClockMS, p.ContextSwitches, p.CPUMigrations, p.PageFaults)
+	switch {
+	case p.IPC > 0:
+		fmt.Fprintf(out, "; IPC %.2f (%d instructions / %d cycles", p.IPC, p.Instructions, p.Cycles)
+		if p.CacheMisses > 0 {
+			fmt.Fprintf(out, ", %d cache misses", p.CacheMisses)
+		}
+		fmt.Fprint(out, ")")
+	case len(p.Unavailable) > 0:
+		fmt.Fprintf(out, "; no %s counters", strings.Join(p.Unavailable, "/"))
+	}
+	if p.UserOnly {
+		fmt.Fprint(out, " [user space only]")
+	}
+	fmt.Fprint(out, "\n")
+}
+
+func printCgroup(out io.Writer, cg *model.CgroupStats) {
+	fmt.Fprintf(out, "Cgroup: cpu %.1fms (user %.1fms sys %.1fms)", cg.CPUUsageMS, cg.CPUUserMS, cg.CPUSystemMS)
+	if cg.MemoryPeakBytes > 0 {
+		fmt.Fprintf(out, ", memory peak %.1f MB", float64(cg.MemoryPeakBytes)/(1<<20))
+	}
+	if cg.IOReadBytes+cg.IOWriteBytes > 0 {
+		fmt.Fprintf(out, ", io read %.1f MB write %.1f MB", float64(cg.IOReadBytes)/(1<<20), float64(cg.IOWriteBytes)/(1<<20))
+	}
+	if cg.PidsPeak > 0 {
+		fmt.Fprintf(out, ", pids peak %d", cg.PidsPeak)
+	}
+	if cg.OOMKills > 0 {
+		fmt.Fprintf(out, ", %d OOM kills", cg.OOMKills)
+	}
+	if len(cg.Missing) > 0 {
+		fmt.Fprintf(out, " [no %s]", strings.Join(cg.Missing, ", "))
+		if cg.MissingReason != "" {
+			fmt.Fprintf(out, "\n  cgroup controllers unavailable: %s", cg.MissingReason)
+		}
+	}
+	fmt.Fprint(out, "\n")
+}
+
+func printLimits(out io.Writer, l *model.CgroupLimits) {
+	fmt.Fprint(out, "Container limits:")
+	if l.CPUQuotaCores > 0 {
+		fmt.Fprintf(out, " cpu %.2g cores", l.CPUQuotaCores)
+	}
+	if l.MemoryMaxBytes > 0 {
+		fmt.Fprintf(out, " memory.max %.0f MB", float64(l.MemoryMaxBytes)/(1<<20))
+	}
+	if l.MemoryHighBytes > 0 {
+		fmt.Fprintf(out, " memory.high %.0f MB", float64(l.MemoryHighBytes)/(1<<20))
+	}
+	fmt.Fprintf(out, "; throttled %d/%d periods (%.1fms), memory high/max events %d/%d", l.ThrottledPeriods, l.Periods, l.ThrottledMS, l.MemoryHighEvents, l.MemoryMaxEvents)
+	if l.OOMKills > 0 {
+		fmt.Fprintf(out, ", %d OOM kills", l.OOMKills)
+	}
+	fmt.Fprint(out, "\n")
+}
+
+func printSyscalls(out io.Writer, stats []model.SyscallStat, limit int) {
+	var calls, errs int64
+	for _, st := range stats {
+		calls += st.Count
+		errs += st.Errors
+	}
+	fmt.Fprintf(out, "Syscalls: %d calls, %d errors\n", calls, errs)
+	fmt.Fprintf(out, "  %-18s %8s %12s %10s %7s\n", "SYSCALL", "CALLS", "TOTAL", "MAX", "ERRORS")
+	for i, st := range stats {
+		if i >= limit {
+			fmt.Fprintf(out, "  ... %d more\n", len(stats)-i)
+			break
+		}
+		fmt.Fprintf(out, "  %-18s %8d %10.1fms %8.1fms %7d\n", st.Name, st.Count, st.TotalMS, st.MaxMS, st.Errors)
+	}
+}
+
+func printNetwork(out io.Writer, dests []model.NetDest, limit int) {
+	fmt.Fprintf(out, "Network destinations:\n")
+	fmt.Fprintf(out, "  %-28s %5s %9s %12s %12s %10s %10s\n", "ADDRESS", "PROTO", "CONNECTS", "CONNECT", "RECV WAIT", "IN", "OUT")
+	for i, d := range dests {
+		if i >= limit {
+			fmt.Fprintf(out, "  ... %d more\n", len(dests)-i)
+			break
+		}
+		connects := fmt.Sprintf("%d", d.Connects)
+		if d.ConnectErrors > 0 {
+			connects += fmt.Sprintf("/%d!", d.ConnectErrors)
+		}
+		fmt.Fprintf(out, "  %-28s %5s %9s %10.1fms %10.1fms %9dB %9dB\n", d.Address, d.Proto, connects, d.ConnectMS, d.RecvBlockedMS, d.BytesIn, d.BytesOut)
+	}
+}
+
+// PrintSyscallDeltas prints the largest syscall count changes between two runs.
+func PrintSyscallDeltas(out io.Writer, deltas []model.SyscallDelta, limit int) {
+	if len(deltas) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "Syscall count changes (A -> B):\n")
+	for i, d := range deltas {
+		if i >= limit {
+			fmt.Fprintf(out, "  ... %d more\n", len(deltas)-i)
+			break
+		}
+		fmt.Fprintf(out, "  %-18s %8d -> %-8d (%+d)\n", d.Name, d.CountA, d.CountB, d.Delta)
+	}
+}
+
+// PrintThreads prints the busiest threads of a sample by CPU time.
+func PrintThreads(out io.Writer, th model.ThreadStats, wallMS float64, limit int) {
+	fmt.Fprintf(out, "Threads: %d seen, %d at peak, %d busy, %.1fms CPU\n", th.Seen, th.Peak, th.Busy, th.CPUMS)
+	fmt.Fprintf(out, "  %-16s %8s %8s %10s %6s\n", "COMM", "PID", "TID", "CPU", "WALL%")
+	for i, t := range th.Top {
+		if i >= limit {
+			fmt.Fprintf(out, "  ... %d more\n", th.Seen-i)
+			break
+		}
+		pct := 0.0
+		if wallMS > 0 {
+			pct = t.CPUMS / wallMS * 100
+		}
+		fmt.Fprintf(out, "  %-16s %8d %8d %8.1fms %5.0f%%\n", t.Comm, t.PID, t.TID, t.CPUMS, pct)
+	}
+}
+
+// PrintFileDirs prints traced file activity rolled up by top-level directory.
+func PrintFileDirs(out io.Writer, files model.FileTrace, dirs []model.DirStat, limit int) {
+	fmt.Fprintf(out, "Files: %d paths, %d opens %d stats %d readdirs, %d errors\n", files.Paths, files.Opens, files.Stats, files.Readdirs, files.Errors)
+	fmt.Fprintf(out, "  %-40s %7s %8s %8s %8s %12s\n", "DIR", "PATHS", "OPENS", "STATS", "READDIR", "TIME")
+	for i, d := range dirs {
+		if i >= limit {
+			fmt.Fprintf(out, "  ... %d more\n", len(dirs)-i)
+			break
+		}
+		fmt.Fprintf(out, "  %-40s %7d %8d %8d %8d %10.1fms\n", d.Dir, d.Paths, d.Opens, d.Stats, d.Readdirs, d.TimeMS)
+	}
+}
+
+// PrintFileSetDiff prints the paths only one of two traced runs touched.
+func PrintFileSetDiff(out io.Writer, diff model.FileSetDiff, limit int) {
+	fmt.Fprintf(out, "File set: %d common, %d only in A, %d only in B\n", diff.Common, len(diff.OnlyA), len(diff.OnlyB))
+	for _, side := range []struct {
+		name  string
+		paths []model.FileAccess
+	}{{"A", diff.OnlyA}, {"B", diff.OnlyB}} {
+		for i, fa := range side.paths {
+			if i >= limit {
+				fmt.Fprintf(out, "  ... %d more only in %s\n", len(side.paths)-i, side.name)
+				break
+			}
+			fmt.Fprintf(out, "  only %s: %s (%.1fms)\n", side.name, fa.Path, fa.TimeMS)
+		}
+	}
+}
+
 func PrintCompareSummary(out io.Writer, a, b model.RunResult, analysis model.Analysis) {
 	fmt.Fprintf(out, "Compare %s -> %s\n", a.ID, b.ID)
 	fmt.Fprintf(out, "A cmd: %s\n", strings.Join(a.Command, " "))
 	fmt.Fprintf(out, "B cmd: %s\n", strings.Join(b.Command, " "))
 	fmt.Fprintf(out, "A: wall %.1fms cpu_ratio %.2f max_rss %d %s exit %d\n", a.WallMS, a.CPURatio, a.MaxRSSRaw, safeUnit(a.MaxRSSUnit), a.ExitCode)
 	fmt.Fprintf(out, "B: wall %.1fms cpu_ratio %.2f max_rss %d %s exit %d\n", b.WallMS, b.CPURatio, b.MaxRSSRaw, safeUnit(b.MaxRSSUnit), b.ExitCode)
+	if a.Environment != nil && b.Environment != nil {
+		fmt.Fprintf(out, "Measurement quality: A %d/100, B %d/100\n", a.Environment.Quality, b.Environment.Quality)
+	}
 
 	top := pickTopExplanation(analysis.Explanations)
 	fmt.Fprintf(out, "Classification (B): %s\n", analysis.Classification)
@@ -105,3 +392,57 @@ func severityScore(s string) int {
 		return 1
 	}
 }
+
+// hookSummary totals hook time per kind, in the order hooks run.
+func hookSummary(run model.RunResult) string {
+	hooks := append([]model.HookRun(nil), run.Hooks...)
+	for _, s := range append(append([]model.Sample(nil), run.Warmup...), run.RawSamples...) {
+		hooks = append(hooks, s.Hooks...)
+	}
+	var parts []string
+	for _, kind := range []string{"setup", "prepare", "cleanup", "teardown"} {
+		var n int
+		var total float64
+		for _, h := range hooks {
+			if h.Kind == kind {
+				n++
+				total += h.WallMS
+			}
+		}
+		switch {
+		case n == 1:
+			parts = append(parts, fmt.Sprintf("%s %.1fms", kind, total))
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%s %dx %.1fms total", kind, n, total))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// cutSummary describes the samples a timeout or signal stopped, which the
+// stats leave out unless every sample was stopped.
+func cutSummary(samples []model.Sample) []string {
+	var cut []string
+	for i, s := range samples {
+		if !s.CutShort() {
+			continue
+		}
+		how := "interrupted"
+		if s.TimedOut {
+			how = "timed out"
+		}
+		cut = append(cut, fmt.Sprintf("sample %d %s at %.1fms", i+1, how, s.WallMS))
+	}
+	return cut
+}
+
+// forwardedSummary names the signals passed on to the last sample that got
+// any.
+func forwardedSummary(samples []model.Sample) string {
+	for i := len(samples) - 1; i >= 0; i-- {
+		if len(samples[i].Forwarded) > 0 {
+			return fmt.Sprintf(", forwarded %s to sample %d", strings.Join(samples[i].Forwarded, ", "), i+1)
+		}
+	}
+	return ""
+}
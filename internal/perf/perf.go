@@ -0,0 +1,35 @@
+// Package perf counts a command's CPU events with perf_event_open. Counters
+// are opened on the thread that starts the command and inherited by it and
+// its descendants, so they cover the whole process tree.
+package perf
+
+import "github.com/barthollomew/why-is-this-slow/internal/model"
+
+// Counters is a set of open perf events. A nil *Counters is valid and reads
+// as nil, so callers need not check whether Open succeeded.
+type Counters struct {
+	events      []event
+	userOnly    bool
+	unavailable []string
+}
+
+type event struct {
+	name string
+	fd   int
+}
+
+// Read returns the current counts scaled for multiplexing.
+func (c *Counters) Read() *model.PerfCounters {
+	if c == nil {
+		return nil
+	}
+	return c.read()
+}
+
+// Close releases the events.
+func (c *Counters) Close() {
+	if c == nil {
+		return
+	}
+	c.close()
+}
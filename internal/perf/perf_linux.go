@@ -0,0 +1,159 @@
+//go:build linux
+
+package perf
+
+import (
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"unsafe"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+const (
+	typeHardware = 0
+	typeSoftware = 1
+
+	flagDisabled      = 1 << 0
+	flagInherit       = 1 << 1
+	flagExcludeKernel = 1 << 5
+	flagExcludeHV     = 1 << 6
+	flagEnableOnExec  = 1 << 12
+
+	formatTotalTimeEnabled = 1 << 0
+	formatTotalTimeRunning = 1 << 1
+
+	flagFDCloexec = 1 << 3
+)
+
+// attr is struct perf_event_attr up to config1 (PERF_ATTR_SIZE_VER0).
+type attr struct {
+	Type         uint32
+	Size         uint32
+	Config       uint64
+	SamplePeriod uint64
+	SampleType   uint64
+	ReadFormat   uint64
+	Flags        uint64
+	WakeupEvents uint32
+	BPType       uint32
+	Config1      uint64
+}
+
+// events are opened in this order; the first is required, the hardware ones
+// are commonly missing in VMs and containers.
+var events = []struct {
+	name     string
+	typ      uint32
+	config   uint64
+	hardware bool
+}{
+	{"task-clock", typeSoftware, 1, false},
+	{"context-switches", typeSoftware, 3, false},
+	{"cpu-migrations", typeSoftware, 4, false},
+	{"page-faults", typeSoftware, 2, false},
+	{"cycles", typeHardware, 0, true},
+	{"instructions", typeHardware, 1, true},
+	{"cache-misses", typeHardware, 3, true},
+}
+
+// Open creates disabled, inherited counters on the calling thread that switch
+// on when a child forked from this thread calls exec. The caller must hold
+// the thread with runtime.LockOSThread until the child has been started. It
+// returns nil when perf events are not available at all.
+//
+// If perf_event_paranoid refuses kernel-side counting, the counters fall back
+// to user space only.
+func Open() *Counters {
+	c := &Counters{}
+	for _, ev := range events {
+		fd, err := open(ev.typ, ev.config, c.userOnly)
+		if isDenied(err) && !c.userOnly {
+			c.userOnly = true
+			fd, err = open(ev.typ, ev.config, true)
+		}
+		if err != nil {
+			if !ev.hardware {
+				c.close()
+				return nil
+			}
+			c.unavailable = append(c.unavailable, ev.name)
+			continue
+		}
+		c.events = append(c.events, event{name: ev.name, fd: fd})
+	}
+	return c
+}
+
+func open(typ uint32, config uint64, userOnly bool) (int, error) {
+	a := attr{
+		Type:       typ,
+		Size:       uint32(unsafe.Sizeof(attr{})),
+		Config:     config,
+		ReadFormat: formatTotalTimeEnabled | formatTotalTimeRunning,
+		Flags:      flagDisabled | flagInherit | flagEnableOnExec,
+	}
+	if userOnly {
+		a.Flags |= flagExcludeKernel | flagExcludeHV
+	}
+	// pid 0 and cpu -1: the calling thread, and the threads it forks, on any CPU.
+	fd, _, errno := syscall.Syscall6(syscall.SYS_PERF_EVENT_OPEN, uintptr(unsafe.Pointer(&a)), 0, ^uintptr(0), ^uintptr(0), flagFDCloexec, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+func isDenied(err error) bool {
+	return errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM)
+}
+
+func (c *Counters) read() *model.PerfCounters {
+	out := &model.PerfCounters{UserOnly: c.userOnly, Unavailable: c.unavailable}
+	buf := make([]byte, 24) // value, time enabled, time running
+	for _, ev := range c.events {
+		n, err := syscall.Read(ev.fd, buf)
+		if err != nil || n != len(buf) {
+			continue
+		}
+		value := binary.NativeEndian.Uint64(buf)
+		enabled := binary.NativeEndian.Uint64(buf[8:])
+		running := binary.NativeEndian.Uint64(buf[16:])
+		if running == 0 {
+			continue
+		}
+		if running < enabled {
+			// The PMU was shared with other events; extrapolate.
+			value = uint64(float64(value) * float64(enabled) / float64(running))
+			out.Multiplexed = true
+		}
+		switch ev.name {
+		case "task-clock":
+			out.TaskClockMS = float64(value) / 1e6
+		case "context-switches":
+			out.ContextSwitches = int64(value)
+		case "cpu-migrations":
+			out.CPUMigrations = int64(value)
+		case "page-faults":
+			out.PageFaults = int64(value)
+		case "cycles":
+			out.Cycles = int64(value)
+		case "instructions":
+			out.Instructions = int64(value)
+		case "cache-misses":
+			out.CacheMisses = int64(value)
+		}
+	}
+	if out.Cycles > 0 && out.Instructions > 0 {
+		out.IPC = float64(out.Instructions) / float64(out.Cycles)
+	}
+	return out
+}
+
+func (c *Counters) close() {
+	for _, ev := range c.events {
+		_ = syscall.Close(ev.fd)
+	}
+	c.events = nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package perf
+
+import "github.com/barthollomew/why-is-this-slow/internal/model"
+
+// Open returns nil: perf events are Linux only.
+func Open() *Counters { return nil }
+
+func (c *Counters) read() *model.PerfCounters { return nil }
+
+func (c *Counters) close() {}
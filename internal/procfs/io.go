@@ -0,0 +1,65 @@
+package procfs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// IO is the content of /proc/<pid>/io. Char counts include page-cache hits;
+// the byte counts are what actually reached or came from storage.
+type IO struct {
+	RChar               uint64
+	WChar               uint64
+	SyscR               uint64
+	SyscW               uint64
+	ReadBytes           uint64
+	WriteBytes          uint64
+	CancelledWriteBytes uint64
+}
+
+// ParseIO parses the "key: value" lines of /proc/<pid>/io.
+func ParseIO(data string) IO {
+	var io IO
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		key, val, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(val), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "rchar":
+			io.RChar = n
+		case "wchar":
+			io.WChar = n
+		case "syscr":
+			io.SyscR = n
+		case "syscw":
+			io.SyscW = n
+		case "read_bytes":
+			io.ReadBytes = n
+		case "write_bytes":
+			io.WriteBytes = n
+		case "cancelled_write_bytes":
+			io.CancelledWriteBytes = n
+		}
+	}
+	return io
+}
+
+// ReadIO reads /proc/<pid>/io. It still works for an exited child that has
+// not been reaped yet, and then covers its whole thread group plus any
+// children it reaped.
+func ReadIO(pid int) (IO, error) {
+	data, err := os.ReadFile(filepath.Join(Root, strconv.Itoa(pid), "io"))
+	if err != nil {
+		return IO{}, err
+	}
+	return ParseIO(string(data)), nil
+}
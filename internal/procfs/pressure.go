@@ -0,0 +1,70 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Pressure is one resource's file under /proc/pressure: the total time, in
+// microseconds since boot, that some or all non-idle tasks were stalled on
+// it, and the share of the last 10 seconds some were. CPU has no meaningful
+// "full" line at the system level.
+type Pressure struct {
+	SomeUS    uint64
+	FullUS    uint64
+	SomeAvg10 float64 // percent
+}
+
+// ParsePressure parses lines of the form
+// "some avg10=0.00 avg60=0.00 avg300=0.00 total=12345".
+func ParsePressure(data string) Pressure {
+	var p Pressure
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var total uint64
+		var avg10 float64
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(f, "total="); ok {
+				total, _ = strconv.ParseUint(v, 10, 64)
+			} else if v, ok := strings.CutPrefix(f, "avg10="); ok {
+				avg10, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		switch fields[0] {
+		case "some":
+			p.SomeUS, p.SomeAvg10 = total, avg10
+		case "full":
+			p.FullUS = total
+		}
+	}
+	return p
+}
+
+// SystemPressure is a snapshot of all three PSI files.
+type SystemPressure struct {
+	CPU    Pressure
+	Memory Pressure
+	IO     Pressure
+}
+
+// ReadSystemPressure reads /proc/pressure/{cpu,memory,io}. It fails on kernels
+// without CONFIG_PSI or booted with psi=0.
+func ReadSystemPressure() (SystemPressure, error) {
+	var sp SystemPressure
+	for _, r := range []struct {
+		name string
+		dst  *Pressure
+	}{{"cpu", &sp.CPU}, {"memory", &sp.Memory}, {"io", &sp.IO}} {
+		data, err := os.ReadFile(filepath.Join(Root, "pressure", r.name))
+		if err != nil {
+			return SystemPressure{}, err
+		}
+		*r.dst = ParsePressure(string(data))
+	}
+	return sp, nil
+}
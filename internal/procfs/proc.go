@@ -0,0 +1,326 @@
+// Package procfs reads the handful of /proc files the runner samples while a
+// command is running. Parsers take raw file contents so they can be tested on
+// any platform; the Read helpers only return data on Linux.
+package procfs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ClockTicks is USER_HZ, the unit of the time fields in /proc/<pid>/stat.
+// It is 100 on every mainstream Linux build and cannot be queried without cgo.
+const ClockTicks = 100
+
+// Root is where procfs is mounted.
+var Root = "/proc"
+
+// Stat holds the fields of /proc/<pid>/stat the runner uses.
+type Stat struct {
+	PID        int
+	Comm       string
+	State      byte
+	PPID       int
+	UTime      uint64 // clock ticks
+	STime      uint64 // clock ticks
+	NumThreads int
+	StartTime  uint64 // clock ticks after boot
+	RSSPages   int64
+	BlkioTicks uint64 // delayacct_blkio_ticks; zero unless delay accounting is on
+}
+
+// CPUTicks is user plus system time in clock ticks.
+func (s Stat) CPUTicks() uint64 {
+	return s.UTime + s.STime
+}
+
+// ParseStat parses the contents of /proc/<pid>/stat. The comm field may
+// contain spaces and parentheses, so fields are split after the last ')'.
+func ParseStat(data string) (Stat, error) {
+	open := strings.IndexByte(data, '(')
+	closing := strings.LastIndexByte(data, ')')
+	if open < 0 || closing < open {
+		return Stat{}, errors.New("malformed stat: missing comm")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(data[:open]))
+	if err != nil {
+		return Stat{}, fmt.Errorf("malformed stat pid: %w", err)
+	}
+	// fields[0] is field 3 (state) in proc(5) numbering.
+	fields := strings.Fields(data[closing+1:])
+	if len(fields) < 22 {
+		return Stat{}, fmt.Errorf("malformed stat: %d fields", len(fields))
+	}
+
+	st := Stat{
+		PID:  pid,
+		Comm: data[open+1 : closing],
+	}
+	if len(fields[0]) > 0 {
+		st.State = fields[0][0]
+	}
+	st.PPID, _ = strconv.Atoi(fields[1])
+	st.UTime, _ = strconv.ParseUint(fields[11], 10, 64)
+	st.STime, _ = strconv.ParseUint(fields[12], 10, 64)
+	st.NumThreads, _ = strconv.Atoi(fields[17])
+	st.StartTime, _ = strconv.ParseUint(fields[19], 10, 64)
+	st.RSSPages, _ = strconv.ParseInt(fields[21], 10, 64)
+	if len(fields) > 39 {
+		st.BlkioTicks, _ = strconv.ParseUint(fields[39], 10, 64)
+	}
+	return st, nil
+}
+
+// ReadStat reads /proc/<pid>/stat.
+func ReadStat(pid int) (Stat, error) {
+	data, err := os.ReadFile(filepath.Join(Root, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return Stat{}, err
+	}
+	return ParseStat(string(data))
+}
+
+// ReadTaskStat reads the stat file of one thread of pid.
+func ReadTaskStat(pid, tid int) (Stat, error) {
+	data, err := os.ReadFile(filepath.Join(Root, strconv.Itoa(pid), "task", strconv.Itoa(tid), "stat"))
+	if err != nil {
+		return Stat{}, err
+	}
+	return ParseStat(string(data))
+}
+
+// ReadTaskWchan returns the kernel function a thread is blocked in. It is
+// empty when the thread is running or the kernel hides the symbol.
+func ReadTaskWchan(pid, tid int) string {
+	data, err := os.ReadFile(filepath.Join(Root, strconv.Itoa(pid), "task", strconv.Itoa(tid), "wchan"))
+	if err != nil {
+		return ""
+	}
+	w := strings.TrimSpace(string(data))
+	if w == "0" {
+		return ""
+	}
+	return w
+}
+
+// Status holds the memory and thread fields of /proc/<pid>/status.
+type Status struct {
+	Tgid    int
+	PPID    int
+	VmRSSKB int64
+	VmHWMKB int64
+	Threads int
+	// CPUsAllowed is the size of the scheduler affinity mask.
+	CPUsAllowed int
+}
+
+// ParseStatus parses the "Key:\tvalue" lines of /proc/<pid>/status.
+func ParseStatus(data string) Status {
+	var st Status
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		key, val, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(val), "kB"))
+		switch key {
+		case "Tgid":
+			st.Tgid, _ = strconv.Atoi(val)
+		case "PPid":
+			st.PPID, _ = strconv.Atoi(val)
+		case "VmRSS":
+			st.VmRSSKB, _ = strconv.ParseInt(val, 10, 64)
+		case "VmHWM":
+			st.VmHWMKB, _ = strconv.ParseInt(val, 10, 64)
+		case "Threads":
+			st.Threads, _ = strconv.Atoi(val)
+		case "Cpus_allowed_list":
+			st.CPUsAllowed = ParseCPUList(val)
+		}
+	}
+	return st
+}
+
+// ParseCPUList counts the CPUs in a kernel CPU list such as "0-3,8,10-11", as
+// found in Cpus_allowed_list and /sys/devices/system/cpu/online.
+func ParseCPUList(list string) int {
+	n := 0
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(lo)
+		if err != nil {
+			continue
+		}
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(hi); err != nil || b < a {
+				continue
+			}
+		}
+		n += b - a + 1
+	}
+	return n
+}
+
+// ReadStatus reads /proc/<pid>/status.
+func ReadStatus(pid int) (Status, error) {
+	data, err := os.ReadFile(filepath.Join(Root, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return Status{}, err
+	}
+	return ParseStatus(string(data)), nil
+}
+
+// ReadCmdline returns the argv of pid. It is empty for zombies and kernel threads.
+func ReadCmdline(pid int) []string {
+	data, err := os.ReadFile(filepath.Join(Root, strconv.Itoa(pid), "cmdline"))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\x00"), "\x00")
+}
+
+// ReadExe returns the path of the executable pid is running.
+func ReadExe(pid int) string {
+	path, err := os.Readlink(filepath.Join(Root, strconv.Itoa(pid), "exe"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(path, " (deleted)")
+}
+
+// CountFDs returns the number of open file descriptors of pid.
+func CountFDs(pid int) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(Root, strconv.Itoa(pid), "fd"))
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// ListPIDs returns every process id currently visible in /proc.
+func ListPIDs() ([]int, error) {
+	entries, err := os.ReadDir(Root)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// Tree returns root and all of its live descendants. It uses the per-task
+// children files when the kernel provides them and falls back to scanning
+// every process's parent pid otherwise.
+func Tree(root int) []int {
+	if pids, ok := treeFromChildren(root); ok {
+		return pids
+	}
+	return treeFromScan(root)
+}
+
+// Children returns the direct children of pid.
+func Children(pid int) []int {
+	if kids, ok := childrenFromTasks(pid); ok {
+		return kids
+	}
+	pids, err := ListPIDs()
+	if err != nil {
+		return nil
+	}
+	var out []int
+	for _, p := range pids {
+		if st, err := ReadStat(p); err == nil && st.PPID == pid {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Uptime returns seconds since boot from /proc/uptime.
+func Uptime() (float64, error) {
+	data, err := os.ReadFile(filepath.Join(Root, "uptime"))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, errors.New("malformed uptime")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+func treeFromChildren(root int) ([]int, bool) {
+	out := []int{root}
+	for i := 0; i < len(out); i++ {
+		kids, ok := childrenFromTasks(out[i])
+		if !ok && i == 0 {
+			return nil, false
+		}
+		out = append(out, kids...)
+	}
+	return out, true
+}
+
+// childrenFromTasks reads /proc/<pid>/task/*/children. ok is false when the
+// kernel does not provide those files or pid is gone.
+func childrenFromTasks(pid int) ([]int, bool) {
+	tasks, err := os.ReadDir(filepath.Join(Root, strconv.Itoa(pid), "task"))
+	if err != nil {
+		return nil, false
+	}
+	var out []int
+	for _, task := range tasks {
+		data, err := os.ReadFile(filepath.Join(Root, strconv.Itoa(pid), "task", task.Name(), "children"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, false
+			}
+			continue
+		}
+		for _, f := range strings.Fields(string(data)) {
+			if kid, err := strconv.Atoi(f); err == nil {
+				out = append(out, kid)
+			}
+		}
+	}
+	return out, true
+}
+
+func treeFromScan(root int) []int {
+	pids, err := ListPIDs()
+	if err != nil {
+		return []int{root}
+	}
+	parent := make(map[int]int, len(pids))
+	for _, pid := range pids {
+		if st, err := ReadStat(pid); err == nil {
+			parent[pid] = st.PPID
+		}
+	}
+	return Descendants(root, parent)
+}
+
+// Descendants walks a pid -> ppid map and returns root followed by every
+// process below it.
+func Descendants(root int, parent map[int]int) []int {
+	children := make(map[int][]int, len(parent))
+	for pid, ppid := range parent {
+		children[ppid] = append(children[ppid], pid)
+	}
+	out := []int{root}
+	for i := 0; i < len(out); i++ {
+		out = append(out, children[out[i]]...)
+	}
+	return out
+}
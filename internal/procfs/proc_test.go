@@ -0,0 +1,101 @@
+package procfs
+
+import "testing"
+
+func TestParseStatCommWithSpaces(t *testing.T) {
+	data := "4242 (my (odd) cmd) S 1 4242 4242 0 -1 4194560 500 0 3 0 150 25 0 0 20 0 7 0 12345 1000000 2048 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 3 0 0 9 0 0\n"
+	st, err := ParseStat(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if st.PID != 4242 || st.Comm != "my (odd) cmd" || st.State != 'S' || st.PPID != 1 {
+		t.Fatalf("unexpected header fields: %+v", st)
+	}
+	if st.UTime != 150 || st.STime != 25 || st.NumThreads != 7 || st.StartTime != 12345 || st.RSSPages != 2048 {
+		t.Fatalf("unexpected counters: %+v", st)
+	}
+	if st.BlkioTicks != 9 {
+		t.Fatalf("blkio ticks = %d", st.BlkioTicks)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	data := "Name:\tcat\nTgid:\t77\nPPid:\t1\nVmHWM:\t    2048 kB\nVmRSS:\t    1024 kB\nThreads:\t4\nCpus_allowed_list:\t0-3,8,10-11\n"
+	st := ParseStatus(data)
+	if st.Tgid != 77 || st.PPID != 1 || st.VmRSSKB != 1024 || st.VmHWMKB != 2048 || st.Threads != 4 || st.CPUsAllowed != 7 {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	parent := map[int]int{10: 1, 11: 10, 12: 11, 13: 10, 20: 1}
+	got := Descendants(10, parent)
+	if len(got) != 4 || got[0] != 10 {
+		t.Fatalf("descendants = %v", got)
+	}
+}
+
+func TestParseSchedstat(t *testing.T) {
+	st, err := ParseSchedstat("123456 7890 12\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if st.RunNS != 123456 || st.RunDelayNS != 7890 || st.Slices != 12 {
+		t.Fatalf("unexpected schedstat: %+v", st)
+	}
+	if _, err := ParseSchedstat("1 2"); err == nil {
+		t.Fatalf("expected error for short schedstat")
+	}
+}
+
+func TestParseIO(t *testing.T) {
+	data := "rchar: 3980\nwchar: 12\nsyscr: 9\nsyscw: 1\nread_bytes: 4096\nwrite_bytes: 8192\ncancelled_write_bytes: 0\n"
+	io := ParseIO(data)
+	if io.RChar != 3980 || io.WChar != 12 || io.SyscR != 9 || io.SyscW != 1 || io.ReadBytes != 4096 || io.WriteBytes != 8192 {
+		t.Fatalf("unexpected io: %+v", io)
+	}
+}
+
+func TestParsePressure(t *testing.T) {
+	data := "some avg10=1.50 avg60=0.20 avg300=0.05 total=3528139\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=2807205\n"
+	p := ParsePressure(data)
+	if p.SomeUS != 3528139 || p.FullUS != 2807205 || p.SomeAvg10 != 1.5 {
+		t.Fatalf("unexpected pressure: %+v", p)
+	}
+	if p := ParsePressure("some avg10=0.00 avg60=0.00 avg300=0.00 total=42\n"); p.SomeUS != 42 || p.FullUS != 0 {
+		t.Fatalf("cpu pressure without a full line: %+v", p)
+	}
+}
+
+func TestParseCPUTimes(t *testing.T) {
+	ct, err := ParseCPUTimes("cpu  49131 0 10420 385852 292 0 7 2390 0 0\ncpu0 49131 0 10420 385852 292 0 7 2390 0 0\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ct.User != 49131 || ct.IOWait != 292 || ct.Steal != 2390 || ct.Total() != 448092 {
+		t.Fatalf("unexpected cpu times: %+v total=%d", ct, ct.Total())
+	}
+	if _, err := ParseCPUTimes("intr 1 2 3\n"); err == nil {
+		t.Fatalf("expected error without a cpu line")
+	}
+	if load, err := ParseLoadavg("0.51 0.22 0.14 2/72 4667\n"); err != nil || load != 0.51 {
+		t.Fatalf("loadavg = %v, %v", load, err)
+	}
+}
+
+func TestParseRollup(t *testing.T) {
+	data := `55d398a68000-7ffdf88e9000 ---p 00000000 00:00 0                          [rollup]
+Rss:                1392 kB
+Pss:                 475 kB
+Pss_Anon:            104 kB
+Pss_File:            371 kB
+Pss_Shmem:             0 kB
+Anonymous:           104 kB
+Swap:                 12 kB
+SwapPss:               6 kB
+`
+	r := ParseRollup(data)
+	if r.RSS != 1392 || r.PSS != 475 || r.PSSAnon != 104 || r.PSSFile != 371 || r.Anon != 104 || r.Swap != 12 || r.SwapPSS != 6 {
+		t.Fatalf("unexpected rollup: %+v", r)
+	}
+}
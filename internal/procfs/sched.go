@@ -0,0 +1,60 @@
+package procfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Schedstat is the content of /proc/<pid>/task/<tid>/schedstat.
+type Schedstat struct {
+	RunNS      uint64 // time spent on a CPU
+	RunDelayNS uint64 // time spent runnable but waiting on a run queue
+	Slices     uint64
+}
+
+// ParseSchedstat parses the three space separated counters of a schedstat file.
+func ParseSchedstat(data string) (Schedstat, error) {
+	fields := strings.Fields(data)
+	if len(fields) < 3 {
+		return Schedstat{}, fmt.Errorf("malformed schedstat: %q", data)
+	}
+	var st Schedstat
+	var err error
+	if st.RunNS, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return Schedstat{}, err
+	}
+	if st.RunDelayNS, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return Schedstat{}, err
+	}
+	if st.Slices, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+		return Schedstat{}, err
+	}
+	return st, nil
+}
+
+// Tasks lists the thread ids of pid.
+func Tasks(pid int) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(Root, strconv.Itoa(pid), "task"))
+	if err != nil {
+		return nil, err
+	}
+	tids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if tid, err := strconv.Atoi(e.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
+
+// ReadTaskSchedstat reads the schedstat of one thread of pid.
+func ReadTaskSchedstat(pid, tid int) (Schedstat, error) {
+	data, err := os.ReadFile(filepath.Join(Root, strconv.Itoa(pid), "task", strconv.Itoa(tid), "schedstat"))
+	if err != nil {
+		return Schedstat{}, err
+	}
+	return ParseSchedstat(string(data))
+}
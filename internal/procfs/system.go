@@ -0,0 +1,74 @@
+package procfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CPUTimes is the aggregate "cpu" line of /proc/stat, in clock ticks.
+type CPUTimes struct {
+	User    uint64
+	Nice    uint64
+	System  uint64
+	Idle    uint64
+	IOWait  uint64
+	IRQ     uint64
+	SoftIRQ uint64
+	Steal   uint64
+}
+
+// Total is the sum of all states. Guest time is already included in user.
+func (c CPUTimes) Total() uint64 {
+	return c.User + c.Nice + c.System + c.Idle + c.IOWait + c.IRQ + c.SoftIRQ + c.Steal
+}
+
+// ParseCPUTimes parses the first line of /proc/stat. Older kernels omit the
+// trailing fields, which are left zero.
+func ParseCPUTimes(data string) (CPUTimes, error) {
+	line, _, _ := strings.Cut(data, "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return CPUTimes{}, errors.New("malformed /proc/stat: no cpu line")
+	}
+	var vals [8]uint64
+	for i := range vals {
+		if i+1 >= len(fields) {
+			break
+		}
+		vals[i], _ = strconv.ParseUint(fields[i+1], 10, 64)
+	}
+	return CPUTimes{
+		User: vals[0], Nice: vals[1], System: vals[2], Idle: vals[3],
+		IOWait: vals[4], IRQ: vals[5], SoftIRQ: vals[6], Steal: vals[7],
+	}, nil
+}
+
+// ReadCPUTimes reads the aggregate CPU times from /proc/stat.
+func ReadCPUTimes() (CPUTimes, error) {
+	data, err := os.ReadFile(filepath.Join(Root, "stat"))
+	if err != nil {
+		return CPUTimes{}, err
+	}
+	return ParseCPUTimes(string(data))
+}
+
+// ParseLoadavg returns the one-minute load average from /proc/loadavg.
+func ParseLoadavg(data string) (float64, error) {
+	fields := strings.Fields(data)
+	if len(fields) == 0 {
+		return 0, errors.New("malformed loadavg")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// ReadLoadavg reads the one-minute load average.
+func ReadLoadavg() (float64, error) {
+	data, err := os.ReadFile(filepath.Join(Root, "loadavg"))
+	if err != nil {
+		return 0, err
+	}
+	return ParseLoadavg(string(data))
+}
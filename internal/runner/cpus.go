@@ -0,0 +1,20 @@
+package runner
+
+import (
+	"github.com/barthollomew/why-is-this-slow/internal/cgroup"
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// countCPUs works out how many CPUs the command can keep busy at once.
+func countCPUs(enc *cgroup.Enclosing) *model.CPUCount {
+	online, affinity := onlineCPUs(), affinityCPUs()
+	c := &model.CPUCount{Online: online, Affinity: affinity, Quota: enc.CPUQuota()}
+	c.Available, c.Limit = float64(online), "online"
+	if affinity > 0 && float64(affinity) < c.Available {
+		c.Available, c.Limit = float64(affinity), "affinity"
+	}
+	if c.Quota > 0 && c.Quota < c.Available {
+		c.Available, c.Limit = c.Quota, "quota"
+	}
+	return c
+}
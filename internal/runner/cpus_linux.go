@@ -0,0 +1,27 @@
+//go:build linux
+
+package runner
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
+)
+
+func onlineCPUs() int {
+	data, err := os.ReadFile("/sys/devices/system/cpu/online")
+	if n := procfs.ParseCPUList(string(data)); err == nil && n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}
+
+// affinityCPUs reads our own mask, which the command inherits.
+func affinityCPUs() int {
+	st, err := procfs.ReadStatus(os.Getpid())
+	if err != nil {
+		return 0
+	}
+	return st.CPUsAllowed
+}
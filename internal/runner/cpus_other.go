@@ -0,0 +1,9 @@
+//go:build !linux
+
+package runner
+
+import "runtime"
+
+func onlineCPUs() int { return runtime.NumCPU() }
+
+func affinityCPUs() int { return 0 }
@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
+)
+
+// busyProcLimit caps how many other processes a run records.
+const busyProcLimit = 5
+
+// envSnapshot is the machine state a run's measurement quality is judged on.
+type envSnapshot struct {
+	at       time.Time
+	load     float64
+	cpu      procfs.CPUTimes
+	procs    map[procKey]procTicks
+	governor string
+	capped   bool
+}
+
+// procKey tells a process from a later one that reused its pid.
+type procKey struct {
+	pid   int
+	start uint64
+}
+
+type procTicks struct {
+	comm  string
+	ticks uint64
+}
+
+// measureEnvironment compares snapshots from before the first sample and
+// after the last. Only processes alive in both count as busy, so the
+// measured command, started in between, is left out.
+func measureEnvironment(start, end *envSnapshot, online int) *model.Environment {
+	if start == nil || end == nil {
+		return nil
+	}
+	env := &model.Environment{
+		LoadStart:  start.load,
+		LoadEnd:    end.load,
+		Governor:   start.governor,
+		FreqCapped: start.capped || end.capped,
+	}
+	if end.cpu.Total() > start.cpu.Total() {
+		total := float64(end.cpu.Total() - start.cpu.Total())
+		env.StealPercent = float64(end.cpu.Steal-start.cpu.Steal) / total * 100
+		env.IOWaitPercent = float64(end.cpu.IOWait-start.cpu.IOWait) / total * 100
+	}
+	if secs := end.at.Sub(start.at).Seconds(); secs > 0 {
+		for k, e := range end.procs {
+			s, ok := start.procs[k]
+			if !ok || e.ticks <= s.ticks {
+				continue
+			}
+			pct := float64(e.ticks-s.ticks) / procfs.ClockTicks / secs * 100
+			if pct >= 1 {
+				env.BusyProcs = append(env.BusyProcs, model.BusyProcess{PID: k.pid, Comm: e.comm, CPUPercent: pct})
+			}
+		}
+		sort.Slice(env.BusyProcs, func(i, j int) bool {
+			if env.BusyProcs[i].CPUPercent != env.BusyProcs[j].CPUPercent {
+				return env.BusyProcs[i].CPUPercent > env.BusyProcs[j].CPUPercent
+			}
+			return env.BusyProcs[i].PID < env.BusyProcs[j].PID
+		})
+	}
+	scoreEnvironment(env, online)
+	if len(env.BusyProcs) > busyProcLimit {
+		env.BusyProcs = env.BusyProcs[:busyProcLimit]
+	}
+	return env
+}
+
+// scoreEnvironment starts from 100 and takes points off for each source of
+// noise, capped so no single one zeroes the score. iowait costs little since
+// the command's own I/O produces it too.
+func scoreEnvironment(env *model.Environment, online int) {
+	if online <= 0 {
+		online = 1
+	}
+	penalty := 0.0
+	issue := func(points float64, format string, args ...any) {
+		penalty += points
+		env.Issues = append(env.Issues, fmt.Sprintf(format, args...))
+	}
+
+	if per := env.LoadStart / float64(online); per > 0.5 {
+		issue(min(30, (per-0.5)*40), "load %.2f on %d CPUs before the run", env.LoadStart, online)
+	}
+	other := 0.0
+	var names []string
+	for i, p := range env.BusyProcs {
+		other += p.CPUPercent
+		if i < 3 {
+			names = append(names, fmt.Sprintf("%s %.0f%%", p.Comm, p.CPUPercent))
+		}
+	}
+	if share := other / float64(online); share > 5 {
+		issue(min(50, share), "other processes used %.0f%% of the CPUs (%s)", share, strings.Join(names, ", "))
+	}
+	if env.StealPercent > 1 {
+		issue(min(30, env.StealPercent*3), "%.1f%% steal time from the hypervisor", env.StealPercent)
+	}
+	if env.IOWaitPercent > 20 {
+		issue(10, "%.0f%% iowait", env.IOWaitPercent)
+	}
+	switch env.Governor {
+	case "powersave", "conservative":
+		// schedutil and ondemand ramp up fast enough under a CPU-bound
+		// command; these two hold the clock low or raise it slowly.
+		issue(10, "CPU frequency governor %s", env.Governor)
+	}
+	if env.FreqCapped {
+		issue(10, "CPU frequency capped below its maximum")
+	}
+	env.Quality = max(0, 100-int(penalty+0.5))
+}
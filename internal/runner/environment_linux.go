@@ -0,0 +1,45 @@
+//go:build linux
+
+package runner
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
+)
+
+const cpufreqDir = "/sys/devices/system/cpu/cpu0/cpufreq/"
+
+// snapshotEnv reads load, CPU times, every other process's CPU ticks and
+// cpu0's frequency policy. Missing files leave their fields zero.
+func snapshotEnv() *envSnapshot {
+	s := &envSnapshot{at: time.Now(), procs: map[procKey]procTicks{}}
+	s.load, _ = procfs.ReadLoadavg()
+	s.cpu, _ = procfs.ReadCPUTimes()
+	self := os.Getpid()
+	pids, _ := procfs.ListPIDs()
+	for _, pid := range pids {
+		if pid == self {
+			continue
+		}
+		if st, err := procfs.ReadStat(pid); err == nil {
+			s.procs[procKey{pid, st.StartTime}] = procTicks{st.Comm, st.CPUTicks()}
+		}
+	}
+	s.governor = readSysfs("scaling_governor")
+	scalingMax, err1 := strconv.Atoi(readSysfs("scaling_max_freq"))
+	hwMax, err2 := strconv.Atoi(readSysfs("cpuinfo_max_freq"))
+	s.capped = err1 == nil && err2 == nil && scalingMax < hwMax
+	return s
+}
+
+func readSysfs(name string) string {
+	data, err := os.ReadFile(cpufreqDir + name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
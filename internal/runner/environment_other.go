@@ -0,0 +1,5 @@
+//go:build !linux
+
+package runner
+
+func snapshotEnv() *envSnapshot { return nil }
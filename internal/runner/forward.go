@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// forwarder relays the signals the runner receives to the process group of
+// the sample that is running. Each sample runs in its own group, so a Ctrl-C
+// on the terminal reaches the runner only, and is passed on and recorded
+// here. Hooks run in groups of their own too and are attached the same way.
+// A nil forwarder, as outside Unix, forwards nothing.
+type forwarder struct {
+	ch chan os.Signal
+	// cancel ends the waits between samples once a signal asks to stop.
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	// pgid is the running sample's group, 0 between samples.
+	pgid int
+	sent []string
+	// cut is set when a signal that ends the run reached the sample.
+	cut bool
+	// stop is set by any signal that asks the runner to end, so no further
+	// samples start.
+	stop bool
+}
+
+// startForwarding installs the signal handlers. cancel is called on the
+// first signal that asks the runner to end.
+func startForwarding(cancel context.CancelFunc) *forwarder {
+	if len(forwardSignals) == 0 {
+		return nil
+	}
+	f := &forwarder{ch: make(chan os.Signal, 4), cancel: cancel}
+	signal.Notify(f.ch, forwardSignals...)
+	go func() {
+		for sig := range f.ch {
+			f.handle(sig)
+		}
+	}()
+	return f
+}
+
+// close restores default signal handling.
+func (f *forwarder) close() {
+	if f == nil {
+		return
+	}
+	signal.Stop(f.ch)
+	close(f.ch)
+}
+
+// attach starts forwarding to a sample's or hook's process group.
+func (f *forwarder) attach(pgid int) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pgid = pgid
+	f.sent = nil
+	f.cut = false
+}
+
+// detach stops forwarding. It returns the signals passed on since attach,
+// and whether one of them ended the sample early.
+func (f *forwarder) detach() ([]string, bool) {
+	if f == nil {
+		return nil, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pgid = 0
+	return f.sent, f.cut
+}
+
+// interrupted reports whether a signal asked the runner to end: the sample
+// in progress is cut short and no further ones start.
+func (f *forwarder) interrupted() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stop
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// hookTailLimit is how much hook output a failure error quotes.
+const hookTailLimit = 2 * 1024
+
+// Hooks are shell commands run around the measured command, outside the
+// timed region. Empty hooks are skipped.
+type Hooks struct {
+	// Setup runs once before the first sample.
+	Setup string
+	// Prepare runs before every sample, warmups included.
+	Prepare string
+	// Cleanup runs after every sample, even one that failed.
+	Cleanup string
+	// Teardown runs once after the last sample, and also when the run is
+	// aborted after Setup succeeded.
+	Teardown string
+}
+
+// runHook runs a setup, prepare, cleanup or teardown hook through the shell
+// in the command's environment, with output on stderr so --json stays
+// parseable.
+func runHook(ctx context.Context, kind, command, cwd string, env []string, signals *forwarder) (model.HookRun, error) {
+	hook := model.HookRun{Kind: kind, Command: command}
+	if err := ctx.Err(); err != nil {
+		return hook, err
+	}
+	cmd := exec.CommandContext(ctx, shell[0], append(shell[1:], command)...)
+	cmd.Dir = cwd
+	newProcessGroup(cmd)
+	cmd.Cancel = func() error { return killGroup(cmd.Process) }
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	// Stdin stays /dev/null: outside the terminal's foreground group a read
+	// from it would stop the hook with SIGTTIN.
+	tail := NewTailWriter(hookTailLimit)
+	cmd.Stdout = io.MultiWriter(os.Stderr, tail)
+	cmd.Stderr = cmd.Stdout
+	start := time.Now()
+	err := cmd.Start()
+	if err == nil {
+		signals.attach(cmd.Process.Pid)
+		err = cmd.Wait()
+		signals.detach()
+	}
+	hook.WallMS = durationMS(time.Since(start))
+	if cmd.ProcessState != nil {
+		hook.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if ctx.Err() != nil {
+		return hook, ctx.Err()
+	}
+	if err != nil {
+		msg := fmt.Sprintf("%s hook %q failed: %v", kind, command, err)
+		if out := strings.TrimSpace(string(tail.Bytes())); out != "" {
+			msg += "\n" + out
+		}
+		return hook, errors.New(msg)
+	}
+	return hook, nil
+}
+
+// shell is how hooks are run.
+var shell = func() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C"}
+	}
+	return []string{"sh", "-c"}
+}()
@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
+)
+
+// idlePoll is how often waitIdle re-reads load and pressure.
+const idlePoll = 500 * time.Millisecond
+
+// IdleWait configures the pause before each sample until the machine is
+// quiet. Zero thresholds are not checked.
+type IdleWait struct {
+	// MaxLoad is the highest 1-minute load average per online CPU.
+	MaxLoad float64
+	// MaxPressure is the highest PSI "some" avg10, in percent, of any of
+	// cpu, memory and io.
+	MaxPressure float64
+	// MaxWait gives up and runs the sample anyway.
+	MaxWait time.Duration
+}
+
+// quiet reports whether load and pressure are under the thresholds. What
+// cannot be read, such as PSI on older kernels or anything outside Linux,
+// does not hold the sample back.
+func (w IdleWait) quiet(online int) bool {
+	if w.MaxLoad > 0 {
+		if load, err := procfs.ReadLoadavg(); err == nil && load/float64(max(online, 1)) > w.MaxLoad {
+			return false
+		}
+	}
+	if w.MaxPressure > 0 {
+		if p, err := procfs.ReadSystemPressure(); err == nil {
+			for _, r := range []procfs.Pressure{p.CPU, p.Memory, p.IO} {
+				if r.SomeAvg10 > w.MaxPressure {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// waitIdle blocks until the machine is quiet or MaxWait has passed, and
+// returns how long it waited and whether it gave up.
+func waitIdle(ctx context.Context, w IdleWait, online int) (time.Duration, bool, error) {
+	start := time.Now()
+	var deadline <-chan time.Time
+	if w.MaxWait > 0 {
+		timer := time.NewTimer(w.MaxWait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	tick := time.NewTicker(idlePoll)
+	defer tick.Stop()
+	for !w.quiet(online) {
+		select {
+		case <-ctx.Done():
+			return time.Since(start), false, ctx.Err()
+		case <-deadline:
+			return time.Since(start), true, nil
+		case <-tick.C:
+		}
+	}
+	return time.Since(start), false, nil
+}
+
+// sleepCtx pauses for d unless ctx is cancelled first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// idleSummary totals the idle waits of a run, or returns nil if neither
+// --wait-idle nor --cooldown was used.
+func idleSummary(opts Options, samples []model.Sample) *model.IdleWait {
+	if opts.WaitIdle == nil && opts.Cooldown <= 0 {
+		return nil
+	}
+	out := &model.IdleWait{CooldownMS: durationMS(opts.Cooldown)}
+	if w := opts.WaitIdle; w != nil {
+		out.MaxLoad, out.MaxPressure, out.MaxWaitMS = w.MaxLoad, w.MaxPressure, durationMS(w.MaxWait)
+	}
+	for _, s := range samples {
+		out.WaitedMS += s.IdleWaitMS
+		if s.IdleTimeout {
+			out.TimedOut++
+		}
+	}
+	return out
+}
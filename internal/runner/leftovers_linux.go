@@ -0,0 +1,159 @@
+//go:build linux
+
+package runner
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
+)
+
+const (
+	prSetChildSubreaper = 36
+	leftoverPollEvery   = 20 * time.Millisecond
+)
+
+// becomeSubreaper makes orphaned descendants of the command re-parent to us
+// instead of init, so they can be listed and their rusage collected.
+func becomeSubreaper() error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// childSet snapshots our current children so processes that existed before
+// a sample are never mistaken for its leftovers.
+func childSet() map[int]bool {
+	out := map[int]bool{}
+	for _, pid := range procfs.Children(os.Getpid()) {
+		out[pid] = true
+	}
+	return out
+}
+
+func newChildren(before map[int]bool) []int {
+	var out []int
+	for _, pid := range procfs.Children(os.Getpid()) {
+		if !before[pid] {
+			out = append(out, pid)
+		}
+	}
+	return out
+}
+
+// collectLeftovers handles descendants that outlived the direct child. They
+// were re-parented to us, so each one is our child now; whatever is below them
+// is listed too. Every leftover that gets reaped adds its rusage to the total.
+func collectLeftovers(ctx context.Context, before map[int]bool, policy LeftoverPolicy) ([]model.LeftoverProcess, Usage) {
+	orphans := newChildren(before)
+	if len(orphans) == 0 {
+		return nil, Usage{}
+	}
+
+	uptime, _ := procfs.Uptime()
+	var procs []model.LeftoverProcess
+	index := map[int]int{}
+	for _, orphan := range orphans {
+		for _, pid := range procfs.Tree(orphan) {
+			st, err := procfs.ReadStat(pid)
+			if err != nil {
+				continue
+			}
+			state := "running"
+			if st.State == 'Z' {
+				state = "exited"
+			}
+			index[pid] = len(procs)
+			procs = append(procs, model.LeftoverProcess{
+				PID:        pid,
+				PPID:       st.PPID,
+				Comm:       st.Comm,
+				LifetimeMS: lifetimeMS(st, uptime),
+				State:      state,
+			})
+		}
+	}
+
+	if policy == LeftoversKill {
+		for _, p := range procs {
+			if p.State == "running" {
+				_ = syscall.Kill(p.PID, syscall.SIGKILL)
+				procs[index[p.PID]].State = "killed"
+			}
+		}
+	}
+
+	var usage Usage
+	block := policy != LeftoversReport
+	for {
+		progress := false
+		pending := newChildren(before)
+		if policy == LeftoversKill {
+			// Orphans forked or re-parented since the snapshot go too, or the
+			// loop would wait on them forever.
+			for _, pid := range pending {
+				_ = syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+		for _, pid := range pending {
+			var ws syscall.WaitStatus
+			var ru syscall.Rusage
+			wpid, err := syscall.Wait4(pid, &ws, syscall.WNOHANG, &ru)
+			if err != nil || wpid != pid {
+				continue
+			}
+			progress = true
+			usage.add(usageFromRusage(&ru))
+			if i, ok := index[pid]; ok && procs[i].State == "running" {
+				procs[i].State = "waited"
+			}
+		}
+		if !block || len(pending) == 0 {
+			break
+		}
+		if ctx.Err() != nil && policy == LeftoversWait {
+			// Give up waiting but do not leave the tree behind.
+			policy = LeftoversKill
+		}
+		if !progress {
+			time.Sleep(leftoverPollEvery)
+		}
+	}
+
+	if block {
+		// Anything below an orphan is gone once the loop drains our children.
+		for i := range procs {
+			if procs[i].State == "running" {
+				procs[i].State = "waited"
+			}
+		}
+	}
+	return procs, usage
+}
+
+// reapExited reaps children that have exited, except those in keep, without
+// blocking. Leftovers reported and left running by an earlier sample end up
+// in every later sample's before set, so nothing else would reap them.
+func reapExited(keep map[int]bool) {
+	for _, pid := range newChildren(keep) {
+		var ws syscall.WaitStatus
+		_, _ = syscall.Wait4(pid, &ws, syscall.WNOHANG, nil)
+	}
+}
+
+func lifetimeMS(st procfs.Stat, uptime float64) float64 {
+	if uptime <= 0 {
+		return 0
+	}
+	secs := uptime - float64(st.StartTime)/procfs.ClockTicks
+	if secs < 0 {
+		return 0
+	}
+	return secs * 1000
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package runner
+
+import (
+	"context"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// Without a subreaper, orphaned descendants go to init and cannot be seen.
+func becomeSubreaper() error {
+	return nil
+}
+
+func childSet() map[int]bool {
+	return map[int]bool{}
+}
+
+func collectLeftovers(ctx context.Context, before map[int]bool, policy LeftoverPolicy) ([]model.LeftoverProcess, Usage) {
+	return nil, Usage{}
+}
+
+func reapExited(keep map[int]bool) {}
@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// OutputMode says where the command's stdout goes.
+type OutputMode string
+
+const (
+	// OutputInherit relays stdout to ours, usually the terminal.
+	OutputInherit OutputMode = "inherit"
+	// OutputNull counts stdout and throws it away.
+	OutputNull OutputMode = "null"
+	// OutputCapture keeps the tail of stdout in the record instead of
+	// printing it.
+	OutputCapture OutputMode = "capture"
+)
+
+// streamCounter counts the bytes and lines relayed to w and the time w.Write
+// blocked. The command's end of the pipe fills up while we are blocked, so
+// that time is roughly how long the command stalled on its output.
+type streamCounter struct {
+	w       io.Writer
+	bytes   atomic.Int64
+	lines   atomic.Int64
+	blocked atomic.Int64 // nanoseconds
+}
+
+func (c *streamCounter) Write(p []byte) (int, error) {
+	c.bytes.Add(int64(len(p)))
+	c.lines.Add(int64(bytes.Count(p, []byte{'\n'})))
+	start := time.Now()
+	n, err := c.w.Write(p)
+	c.blocked.Add(int64(time.Since(start)))
+	return n, err
+}
+
+func (c *streamCounter) stats() model.StreamStats {
+	return model.StreamStats{
+		Bytes:     c.bytes.Load(),
+		Lines:     c.lines.Load(),
+		BlockedMS: float64(c.blocked.Load()) / float64(time.Millisecond),
+	}
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package runner
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ptyRelay gives the command a pseudo-terminal as stdout and copies what it
+// prints on to ours, so inherited output can be counted while the command
+// still sees a terminal.
+type ptyRelay struct {
+	tty  *os.File // the command's end
+	pty  *os.File
+	done chan struct{}
+}
+
+// newPtyRelay opens a pty set up like term and starts copying from it to w.
+func newPtyRelay(term *os.File, w io.Writer) (*ptyRelay, error) {
+	pty, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	var unlock int32
+	var n uint32
+	err = ioctl(pty, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock))
+	if err == nil {
+		err = ioctl(pty, syscall.TIOCGPTN, unsafe.Pointer(&n))
+	}
+	if err != nil {
+		pty.Close()
+		return nil, err
+	}
+	tty, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		pty.Close()
+		return nil, err
+	}
+	// Same modes and size as our terminal, except output processing, which
+	// our terminal does again on the way out.
+	var t syscall.Termios
+	if ioctl(term, syscall.TCGETS, unsafe.Pointer(&t)) == nil {
+		t.Oflag &^= syscall.OPOST
+		_ = ioctl(tty, syscall.TCSETS, unsafe.Pointer(&t))
+	}
+	var size [4]uint16
+	if ioctl(term, syscall.TIOCGWINSZ, unsafe.Pointer(&size)) == nil {
+		_ = ioctl(tty, syscall.TIOCSWINSZ, unsafe.Pointer(&size))
+	}
+
+	r := &ptyRelay{tty: tty, pty: pty, done: make(chan struct{})}
+	go func() {
+		// Reads fail with EIO once every writer has closed the tty.
+		_, _ = io.Copy(w, pty)
+		close(r.done)
+	}()
+	return r, nil
+}
+
+// finish is called once the command has exited. Like Wait with a pipe, it
+// keeps copying for up to pipeWaitDelay; leftovers still holding the tty
+// get EIO after that.
+func (r *ptyRelay) finish() {
+	r.tty.Close()
+	_ = r.pty.SetReadDeadline(time.Now().Add(pipeWaitDelay))
+	<-r.done
+	r.pty.Close()
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package runner
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ptyRelay is Linux only; elsewhere inherited stdout is relayed through a
+// pipe.
+type ptyRelay struct {
+	tty *os.File
+}
+
+func newPtyRelay(term *os.File, w io.Writer) (*ptyRelay, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (r *ptyRelay) finish() {}
@@ -0,0 +1,45 @@
+//go:build linux
+
+package runner
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
+)
+
+const pPID = 1 // P_PID idtype for waitid
+
+// waitExited blocks until pid has exited but leaves it unreaped, so its /proc
+// entries (notably io) can still be read.
+func waitExited(pid int) error {
+	var info [128]byte // siginfo_t
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(pid), uintptr(unsafe.Pointer(&info[0])), syscall.WEXITED|syscall.WNOWAIT, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
+
+// exitedIO reads the I/O counters of an exited, unreaped child.
+func exitedIO(pid int) *model.IOCounters {
+	io, err := procfs.ReadIO(pid)
+	if err != nil {
+		return nil
+	}
+	return &model.IOCounters{
+		ReadChars:  int64(io.RChar),
+		WriteChars: int64(io.WChar),
+		ReadCalls:  int64(io.SyscR),
+		WriteCalls: int64(io.SyscW),
+		ReadBytes:  int64(io.ReadBytes),
+		WriteBytes: int64(io.WriteBytes),
+	}
+}
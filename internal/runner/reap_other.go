@@ -0,0 +1,14 @@
+//go:build !linux
+
+package runner
+
+import "github.com/barthollomew/why-is-this-slow/internal/model"
+
+// waitExited is a no-op without waitid; the caller reaps normally.
+func waitExited(pid int) error {
+	return nil
+}
+
+func exitedIO(pid int) *model.IOCounters {
+	return nil
+}
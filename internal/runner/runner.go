@@ -7,22 +7,85 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"syscall"
 	"time"
 
+	"github.com/barthollomew/why-is-this-slow/internal/cgroup"
 	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/perf"
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
 	"github.com/barthollomew/why-is-this-slow/internal/stats"
 )
 
-const stderrLimit = 64 * 1024
+const (
+	stderrLimit = 64 * 1024
+	stdoutLimit = 64 * 1024
+	// defaultPollInterval is how often /proc counters are read when no
+	// timeline was requested.
+	defaultPollInterval = 100 * time.Millisecond
+	// pipeWaitDelay bounds how long Wait keeps copying stderr after the child
+	// exits, in case a leftover descendant inherited the pipe.
+	pipeWaitDelay = 100 * time.Millisecond
+)
+
+// LeftoverPolicy says what to do with descendants still running when the
+// direct child exits.
+type LeftoverPolicy string
+
+const (
+	LeftoversReport LeftoverPolicy = "report"
+	LeftoversKill   LeftoverPolicy = "kill"
+	LeftoversWait   LeftoverPolicy = "wait"
+)
 
 type Options struct {
 	Command []string
 	CWD     string
 	Repeat  int
+	// Warmup runs this many samples first and keeps them out of the stats.
+	Warmup int
+	// SampleInterval enables the /proc timeline sampler (Linux only).
+	SampleInterval time.Duration
+	// SampleDetail adds open-fd counts to the timeline (Linux only).
+	SampleDetail bool
+	// Leftovers defaults to LeftoversReport.
+	Leftovers LeftoverPolicy
+	// TraceProcs follows the process tree with ptrace (Linux only).
+	TraceProcs bool
+	// Syscalls counts and times syscalls with ptrace (Linux only).
+	Syscalls bool
+	// TraceNet records connect and receive time per remote address with
+	// ptrace (Linux only).
+	TraceNet bool
+	// TraceFiles records the paths the process tree opens, stats and lists
+	// with ptrace (Linux only).
+	TraceFiles bool
+	// Cgroup runs each sample in its own transient cgroup v2 group below the
+	// current one and records its accounting (Linux only).
+	Cgroup bool
+	// WaitIdle, if set, pauses before each sample until load and pressure
+	// drop below its thresholds.
+	WaitIdle *IdleWait
+	// Cooldown pauses between samples.
+	Cooldown time.Duration
+	// Stdout defaults to OutputInherit.
+	Stdout OutputMode
+	// Hooks run around samples, outside the timed region.
+	Hooks Hooks
+	// Env adds KEY=VALUE pairs to the environment of the command and hooks.
+	Env []string
+	// Timeout stops a sample that runs longer: SIGTERM, then SIGKILL after
+	// KillGrace (default 5s).
+	Timeout   time.Duration
+	KillGrace time.Duration
+	// MaxTotalTime stops starting new samples once the run has taken this
+	// long. At least one measured sample always runs.
+	MaxTotalTime time.Duration
 }
 
 // execute runs the command n times and captures timing and usage.
@@ -33,9 +96,23 @@ func Execute(ctx context.Context, opts Options) (model.RunResult, error) {
 	if opts.Repeat < 1 {
 		opts.Repeat = 1
 	}
+	if opts.Warmup < 0 {
+		opts.Warmup = 0
+	}
+	if opts.Leftovers == "" {
+		opts.Leftovers = LeftoversReport
+	}
+	if opts.Stdout == "" {
+		opts.Stdout = OutputInherit
+	}
+	// Best effort: without it orphans simply go to init as before.
+	_ = becomeSubreaper()
+	began := time.Now()
 
 	var samples []model.Sample
+	var leftovers []model.LeftoverProcess
 	var stderrTail string
+	var stdoutTail string
 	var exitCode int
 	var signal string
 	var maxRSS int64
@@ -52,18 +129,129 @@ func Execute(ctx context.Context, opts Options) (model.RunResult, error) {
 		cwd = val
 	}
 
-	for i := 0; i < opts.Repeat; i++ {
-		sample, tail, err := runOnce(ctx, opts.Command, cwd)
-		if ctx.Err() != nil {
-			return model.RunResult{}, ctx.Err()
+	var env sampleEnv
+	if opts.Cgroup {
+		p, err := cgroup.Setup()
+		if err != nil {
+			return model.RunResult{}, fmt.Errorf("cgroup: %w", err)
+		}
+		env.cgroup = p
+	}
+	// Best effort: outside Linux or cgroup v2 there are no limits to watch.
+	env.enclosing, _ = cgroup.FindEnclosing()
+	// waitCtx also ends on a forwarded signal. It bounds the waits and hooks
+	// before a sample, so a Ctrl-C between samples stops the run at once;
+	// samples, cleanup and teardown keep ctx and get the signal instead.
+	waitCtx, stopWaits := context.WithCancel(ctx)
+	defer stopWaits()
+	env.signals = startForwarding(stopWaits)
+	defer env.signals.close()
+	env.children = childSet()
+
+	var hooks []model.HookRun
+	if opts.Hooks.Setup != "" {
+		hook, err := runHook(waitCtx, "setup", opts.Hooks.Setup, cwd, opts.Env, env.signals)
+		hooks = append(hooks, hook)
+		if err != nil {
+			return model.RunResult{}, err
+		}
+	}
+	tornDown := opts.Hooks.Teardown == ""
+	defer func() {
+		if !tornDown {
+			// Best effort after an abort; the abort's error is what matters.
+			_, _ = runHook(context.WithoutCancel(ctx), "teardown", opts.Hooks.Teardown, cwd, opts.Env, env.signals)
+		}
+	}()
+
+	var envStart *envSnapshot
+	var warmups []model.Sample
+	// cancelled is ctx's error once it ended the run; the samples so far are
+	// still returned with it.
+	var cancelled error
+	online := onlineCPUs()
+	for i := 0; i < opts.Warmup+opts.Repeat; i++ {
+		if env.signals.interrupted() || cancelled != nil {
+			break
+		}
+		if i > opts.Warmup && opts.MaxTotalTime > 0 && time.Since(began) >= opts.MaxTotalTime {
+			break
+		}
+		// An error with the run interrupted or ctx done is the signal or
+		// cancellation, not a failure: stop and keep the samples so far.
+		if i > 0 && opts.Cooldown > 0 {
+			if err := sleepCtx(waitCtx, opts.Cooldown); err != nil {
+				if cancelled = ctx.Err(); cancelled != nil || env.signals.interrupted() {
+					break
+				}
+				return model.RunResult{}, err
+			}
+		}
+		var sampleHooks []model.HookRun
+		if opts.Hooks.Prepare != "" {
+			hook, err := runHook(waitCtx, "prepare", opts.Hooks.Prepare, cwd, opts.Env, env.signals)
+			sampleHooks = append(sampleHooks, hook)
+			if err != nil {
+				if cancelled = ctx.Err(); cancelled != nil || env.signals.interrupted() {
+					break
+				}
+				return model.RunResult{}, err
+			}
+		}
+		var idleWaited time.Duration
+		var idleTimeout bool
+		if opts.WaitIdle != nil {
+			var err error
+			idleWaited, idleTimeout, err = waitIdle(waitCtx, *opts.WaitIdle, online)
+			if err != nil {
+				if cancelled = ctx.Err(); cancelled != nil || env.signals.interrupted() {
+					break
+				}
+				return model.RunResult{}, err
+			}
 		}
-		if err != nil && !isExitCodeError(err) {
+		if i == opts.Warmup {
+			// After warmups and any idle wait, so noise we waited out does
+			// not count.
+			envStart = snapshotEnv()
+		}
+
+		reapExited(env.children)
+		sample, tails, err := runOnce(ctx, opts, cwd, env)
+		if cancelled = ctx.Err(); cancelled != nil {
+			// runOnce only leaves Output unset when the sample never ran. One
+			// that did is kept, cut short, and ends the loop below.
+			if sample.Output == nil || i < opts.Warmup {
+				break
+			}
+			sample.Interrupted = true
+		} else if err != nil && !isExitCodeError(err) {
 			return model.RunResult{}, err
 		}
+		if opts.Hooks.Cleanup != "" && cancelled == nil {
+			hook, err := runHook(ctx, "cleanup", opts.Hooks.Cleanup, cwd, opts.Env, env.signals)
+			sampleHooks = append(sampleHooks, hook)
+			if err != nil && !env.signals.interrupted() {
+				if cancelled = ctx.Err(); cancelled == nil {
+					return model.RunResult{}, err
+				}
+			}
+		}
+		sample.Hooks = sampleHooks
+		sample.IdleWaitMS = durationMS(idleWaited)
+		sample.IdleTimeout = idleTimeout
+		if i < opts.Warmup {
+			warmups = append(warmups, sample)
+			continue
+		}
 
 		samples = append(samples, sample)
-		if tail != "" {
-			stderrTail = tail
+		leftovers = append(leftovers, sample.Leftovers...)
+		if tails.stderr != "" {
+			stderrTail = tails.stderr
+		}
+		if tails.stdout != "" {
+			stdoutTail = tails.stdout
 		}
 		if sample.ExitCode != 0 {
 			exitCode = sample.ExitCode
@@ -79,35 +267,90 @@ func Execute(ctx context.Context, opts Options) (model.RunResult, error) {
 		cpuForAggregate = sample.CPURatio
 	}
 
-	medianWall := stats.Median(getWall(samples))
-	p90Wall := stats.Percentile(getWall(samples), 90)
-	medianCPU := stats.Median(getCPU(samples))
-	userMed := stats.Median(getUser(samples))
-	sysMed := stats.Median(getSys(samples))
+	if len(samples) == 0 {
+		if cancelled != nil {
+			return model.RunResult{}, cancelled
+		}
+		return model.RunResult{}, errors.New("interrupted before the first measured sample")
+	}
+
+	// Samples stopped by --timeout or a forwarded signal measured the stop,
+	// not the command, so they stay out of the aggregates unless nothing else
+	// is left.
+	measured := samples
+	if kept := completedSamples(samples); len(kept) > 0 {
+		measured = kept
+	}
+
+	medianWall := stats.Median(getWall(measured))
+	p90Wall := stats.Percentile(getWall(measured), 90)
+	medianCPU := stats.Median(getCPU(measured))
+	userMed := stats.Median(getUser(measured))
+	sysMed := stats.Median(getSys(measured))
 
 	run := model.RunResult{
-		ID:         newRunID(),
-		Timestamp:  time.Now().UTC(),
-		Command:    opts.Command,
-		CWD:        cwd,
-		Platform:   fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
-		WallMS:     medianWall,
-		UserMS:     userMed,
-		SysMS:      sysMed,
-		CPURatio:   medianCPU,
-		MaxRSSRaw:  maxRSS,
-		MaxRSSUnit: maxRSSUnit,
-		ExitCode:   exitCode,
-		Signal:     signal,
+		ID:          newRunID(),
+		Timestamp:   time.Now().UTC(),
+		Command:     opts.Command,
+		CWD:         cwd,
+		Platform:    fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+		CPUs:        countCPUs(env.enclosing),
+		WallMS:      medianWall,
+		UserMS:      userMed,
+		SysMS:       sysMed,
+		CPURatio:    medianCPU,
+		MaxRSSRaw:   maxRSS,
+		MaxRSSUnit:  maxRSSUnit,
+		ExitCode:    exitCode,
+		Signal:      signal,
+		MinorFaults: medianInt(measured, func(s model.Sample) int64 { return s.MinorFaults }),
+		MajorFaults: medianInt(measured, func(s model.Sample) int64 { return s.MajorFaults }),
+		InBlock:     medianInt(measured, func(s model.Sample) int64 { return s.InBlock }),
+		OutBlock:    medianInt(measured, func(s model.Sample) int64 { return s.OutBlock }),
+		VolCtxSw:    medianInt(measured, func(s model.Sample) int64 { return s.VolCtxSw }),
+		InvolCtxSw:  medianInt(measured, func(s model.Sample) int64 { return s.InvolCtxSw }),
+		RunDelayMS:  stats.Median(sampleValues(measured, func(s model.Sample) float64 { return s.RunDelayMS })),
 	}
 
 	if stderrTail != "" {
 		run.StderrTail = stderrTail
 	}
+	run.StdoutTail = stdoutTail
+	run.Output = medianOutput(measured)
+	run.WaitStates = mergeWaitStates(measured)
+	run.Leftovers = leftovers
+	if len(leftovers) > 0 {
+		run.LeftoverPolicy = string(opts.Leftovers)
+	}
+	run.IO = medianIO(measured)
+	run.Perf = medianPerf(measured)
+	run.Cgroup = medianCgroup(measured)
+	run.Limits = mergeLimits(samples)
+	run.Pressure = medianPressure(measured)
+	run.Memory = peakMemory(measured)
+	run.Threads = medianThreads(measured)
+	run.Syscalls = mergeSyscalls(measured)
+	run.Network = mergeNetwork(measured)
+	run.Files = mergeFiles(samples)
+	run.Environment = measureEnvironment(envStart, snapshotEnv(), run.CPUs.Online)
+	run.Idle = idleSummary(opts, append(warmups, samples...))
+	run.Warmup = warmups
+	run.TimeoutMS = durationMS(opts.Timeout)
+	run.Interrupted = env.signals.interrupted() || cancelled != nil
+	for _, s := range samples {
+		if s.TimedOut {
+			run.TimedOut++
+		}
+	}
+	if opts.SampleInterval > 0 {
+		run.SampleIntervalMS = durationMS(opts.SampleInterval)
+	}
 
 	if opts.Repeat > 1 {
 		run.Repeat = &model.Repeat{
-			Count:          opts.Repeat,
+			Count:          len(samples),
+			Requested:      opts.Repeat,
+			MaxTotalMS:     durationMS(opts.MaxTotalTime),
 			MedianWallMS:   medianWall,
 			P90WallMS:      p90Wall,
 			MedianCPURatio: medianCPU,
@@ -123,67 +366,292 @@ func Execute(ctx context.Context, opts Options) (model.RunResult, error) {
 		run.CPURatio = cpuForAggregate
 	}
 
+	if cancelled != nil {
+		// Teardown is left to the deferred best-effort run, as after any
+		// other abort.
+		run.Hooks = hooks
+		return run, cancelled
+	}
+	if !tornDown {
+		tornDown = true
+		hook, err := runHook(ctx, "teardown", opts.Hooks.Teardown, cwd, opts.Env, env.signals)
+		hooks = append(hooks, hook)
+		if err != nil {
+			return model.RunResult{}, err
+		}
+	}
+	run.Hooks = hooks
+
 	return run, nil
 }
 
-func runOnce(ctx context.Context, command []string, cwd string) (model.Sample, string, error) {
+// exitResult is what waiting for one sample's direct child produced.
+type exitResult struct {
+	elapsed   time.Duration
+	usage     Usage
+	exitCode  int
+	signal    string
+	proc      procStats
+	io        *model.IOCounters
+	perf      *model.PerfCounters
+	cgroup    *model.CgroupStats
+	limits    *model.CgroupLimits
+	leftovers []model.LeftoverProcess
+	procTree  []model.ProcNode
+	syscalls  []model.SyscallStat
+	network   []model.NetDest
+	files     *model.FileTrace
+	timedOut  bool
+	forwarded []string
+	cut       bool
+	waitErr   error
+}
+
+// sampleEnv is state set up once per Execute and used by every sample.
+type sampleEnv struct {
+	cgroup    *cgroup.Parent
+	enclosing *cgroup.Enclosing
+	signals   *forwarder
+	// children are the runner's own children from before the first sample,
+	// which are never reaped as leftovers.
+	children map[int]bool
+}
+
+// outputTails are the last bytes a sample wrote; stdout only with
+// OutputCapture.
+type outputTails struct {
+	stdout string
+	stderr string
+}
+
+func runOnce(ctx context.Context, opts Options, cwd string, env sampleEnv) (model.Sample, outputTails, error) {
+	command := opts.Command
 	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
 	cmd.Dir = cwd
+	newProcessGroup(cmd)
+	cmd.Cancel = func() error { return killGroup(cmd.Process) }
+	if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), opts.Env...)
+	}
+
+	var group *cgroup.Group
+	if env.cgroup != nil {
+		g, err := env.cgroup.NewGroup()
+		if err != nil {
+			return model.Sample{}, outputTails{}, fmt.Errorf("cgroup: %w", err)
+		}
+		defer g.Remove()
+		g.Attach(cmd)
+		group = g
+	}
 
 	tail := NewTailWriter(stderrLimit)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = io.MultiWriter(os.Stderr, tail)
+	stdout := &streamCounter{w: os.Stdout}
+	var stdoutTail *TailWriter
+	switch opts.Stdout {
+	case OutputNull:
+		stdout.w = io.Discard
+	case OutputCapture:
+		stdoutTail = NewTailWriter(stdoutLimit)
+		stdout.w = stdoutTail
+	}
+	cmd.Stdout = stdout
+	terminal := opts.Stdout == OutputInherit && isTerminal(os.Stdout)
+	var relay *ptyRelay
+	if terminal {
+		// Keep the command on a terminal, with its buffering and colours,
+		// while still counting what it prints.
+		if r, err := newPtyRelay(os.Stdout, stdout); err == nil {
+			relay = r
+			cmd.Stdout = r.tty
+		}
+	}
+	stderr := &streamCounter{w: os.Stderr}
+	cmd.Stderr = io.MultiWriter(stderr, tail)
+	cmd.WaitDelay = pipeWaitDelay
+
+	limitsBefore := env.enclosing.Snapshot()
+	pressureBefore, pressureErr := procfs.ReadSystemPressure()
+	var res exitResult
+	var err error
+	if opts.TraceProcs || opts.Syscalls || opts.TraceNet || opts.TraceFiles {
+		res, err = runTraced(ctx, cmd, opts, env)
+	} else {
+		res, err = runPlain(ctx, cmd, opts, env)
+	}
+	if relay != nil {
+		relay.finish()
+	}
+	if err != nil {
+		return model.Sample{}, outputTails{}, err
+	}
+	if group != nil {
+		// Read once leftovers were dealt with, like their rusage.
+		res.cgroup = group.Stats()
+	}
+	res.limits = env.enclosing.Limits(limitsBefore, env.enclosing.Snapshot())
+	var pressure *model.Pressure
+	if pressureErr == nil {
+		if after, err := procfs.ReadSystemPressure(); err == nil {
+			pressure = pressureDelta(pressureBefore, after)
+		}
+	}
+
+	usage := res.usage
+	wallMs := durationMS(res.elapsed)
+	cpuRatio := 0.0
+	if wallMs > 0 {
+		cpuRatio = (usage.UserMS + usage.SysMS) / wallMs
+	}
 
+	sample := model.Sample{
+		WallMS:      wallMs,
+		UserMS:      usage.UserMS,
+		SysMS:       usage.SysMS,
+		CPURatio:    cpuRatio,
+		MaxRSS:      usage.MaxRSS,
+		MaxRSSUnit:  usage.MaxRSSUnit,
+		MinorFaults: usage.MinorFaults,
+		MajorFaults: usage.MajorFaults,
+		InBlock:     usage.InBlock,
+		OutBlock:    usage.OutBlock,
+		VolCtxSw:    usage.VolCtxSw,
+		InvolCtxSw:  usage.InvolCtxSw,
+		ExitCode:    res.exitCode,
+		Signal:      res.signal,
+		RunDelayMS:  res.proc.RunDelayMS,
+		Timeline:    res.proc.Timeline,
+		IO:          res.io,
+		Perf:        res.perf,
+		Cgroup:      res.cgroup,
+		Limits:      res.limits,
+		Pressure:    pressure,
+		Memory:      res.proc.Memory,
+		Threads:     threadStats(res.proc.Threads, res.proc.PeakThreads, wallMs),
+		Leftovers:   res.leftovers,
+		ProcTree:    res.procTree,
+		Syscalls:    res.syscalls,
+		Network:     res.network,
+		Files:       res.files,
+		TimedOut:    res.timedOut,
+		Interrupted: res.cut,
+		Forwarded:   res.forwarded,
+	}
+	if ws := res.proc.WaitStates; ws.RunningPolls+ws.DiskPolls+ws.SleepPolls > 0 || ws.BlkioDelayMS > 0 {
+		sample.WaitStates = &ws
+	}
+
+	sample.Output = &model.Output{
+		Mode:     string(opts.Stdout),
+		Terminal: terminal,
+		Stdout:   stdout.stats(),
+		Stderr:   stderr.stats(),
+	}
+	tails := outputTails{stderr: string(tail.Bytes())}
+	if stdoutTail != nil {
+		tails.stdout = string(stdoutTail.Bytes())
+	}
+	return sample, tails, res.waitErr
+}
+
+// runPlain starts cmd and waits for it without tracing.
+func runPlain(ctx context.Context, cmd *exec.Cmd, opts Options, env sampleEnv) (exitResult, error) {
+	before := childSet()
 	start := time.Now()
+	// Counters are inherited by children of the opening thread, so the child
+	// must be forked from the same one.
+	runtime.LockOSThread()
+	counters := perf.Open()
 	err := cmd.Start()
+	runtime.UnlockOSThread()
+	defer counters.Close()
 	if err != nil {
-		return model.Sample{}, "", err
+		return exitResult{}, err
+	}
+	smp := startSampler(cmd.Process.Pid, start, pollInterval(opts), opts.SampleInterval > 0, opts.SampleDetail)
+	timeout := startTimeout(cmd.Process, opts.Timeout, opts.KillGrace)
+	env.signals.attach(cmd.Process.Pid)
+
+	// Wait for exit without reaping so the final /proc readings still see the
+	// child; a waitid failure only costs those readings.
+	var res exitResult
+	exitErr := waitExited(cmd.Process.Pid)
+	res.elapsed = time.Since(start)
+	res.timedOut = timeout.Stop()
+	res.proc = smp.Stop()
+	if exitErr == nil {
+		res.io = exitedIO(cmd.Process.Pid)
 	}
 
-	waitErr := cmd.Wait()
-	elapsed := time.Since(start)
+	// Leftovers are handled before reaping: with --wait-leftovers their output
+	// keeps streaming, and a daemon holding our stderr pipe is dealt with
+	// before Wait would block on it.
+	policy := stopGroup(ctx, cmd, opts, env, res.timedOut)
+	var leftoverUsage Usage
+	if exitErr == nil {
+		before[cmd.Process.Pid] = true
+		res.leftovers, leftoverUsage = collectLeftovers(ctx, before, policy)
+	}
+	res.forwarded, res.cut = env.signals.detach()
+	// Read after the leftovers, whose counts are included like their rusage.
+	res.perf = counters.Read()
+
+	res.waitErr = cmd.Wait()
+	if errors.Is(res.waitErr, exec.ErrWaitDelay) {
+		// A leftover still holds stderr; its later output is not captured.
+		res.waitErr = nil
+	}
+	if exitErr != nil {
+		res.elapsed = time.Since(start)
+	}
 
 	usage, ok := childUsage(cmd.ProcessState)
 	if !ok {
 		usage.MaxRSSUnit = "unknown"
 	}
+	usage.add(leftoverUsage)
+	res.usage = usage
+	res.exitCode, res.signal = exitInfo(cmd.ProcessState, res.waitErr)
+	return res, nil
+}
 
-	exitCode, signal := exitInfo(cmd.ProcessState, waitErr)
-	wallMs := float64(elapsed) / float64(time.Millisecond)
-	cpuRatio := 0.0
-	if wallMs > 0 {
-		cpuRatio = (usage.UserMS + usage.SysMS) / wallMs
+// stopGroup kills what is left of a sample's process group when the sample
+// was cut short by a timeout, a forwarded signal or cancellation, and
+// returns the leftover policy that then reaps all of it.
+func stopGroup(ctx context.Context, cmd *exec.Cmd, opts Options, env sampleEnv, timedOut bool) LeftoverPolicy {
+	if !timedOut && !env.signals.interrupted() && ctx.Err() == nil {
+		return opts.Leftovers
 	}
+	_ = killGroup(cmd.Process)
+	return LeftoversKill
+}
 
-	sample := model.Sample{
-		WallMS:     wallMs,
-		UserMS:     usage.UserMS,
-		SysMS:      usage.SysMS,
-		CPURatio:   cpuRatio,
-		MaxRSS:     usage.MaxRSS,
-		MaxRSSUnit: usage.MaxRSSUnit,
-		ExitCode:   exitCode,
-		Signal:     signal,
+func pollInterval(opts Options) time.Duration {
+	if opts.SampleInterval > 0 {
+		return opts.SampleInterval
 	}
-
-	return sample, string(tail.Bytes()), waitErr
+	return defaultPollInterval
 }
 
 func exitInfo(ps *os.ProcessState, waitErr error) (int, string) {
-	exitCode := 0
-	signal := ""
 	if ws, ok := ps.Sys().(syscall.WaitStatus); ok {
-		if ws.Signaled() {
-			sigName := ws.Signal().String()
-			signal = sigName
-			exitCode = 128 + int(ws.Signal())
-		} else {
-			exitCode = ws.ExitStatus()
-		}
-	} else if waitErr != nil {
-		exitCode = 1
+		return statusExit(ws)
+	}
+	if waitErr != nil {
+		return 1, ""
 	}
-	return exitCode, signal
+	return 0, ""
+}
+
+func statusExit(ws syscall.WaitStatus) (int, string) {
+	if ws.Signaled() {
+		return 128 + int(ws.Signal()), ws.Signal().String()
+	}
+	return ws.ExitStatus(), ""
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
 }
 
 func isExitCodeError(err error) bool {
@@ -230,3 +698,385 @@ func getSys(samples []model.Sample) []float64 {
 	}
 	return out
 }
+
+// mergeWaitStates sums poll counts across samples so their ratios stay
+// meaningful, and takes the median block I/O delay like the other timings.
+func mergeWaitStates(samples []model.Sample) *model.WaitStates {
+	var out *model.WaitStates
+	var blkio []float64
+	for _, s := range samples {
+		if s.WaitStates == nil {
+			continue
+		}
+		if out == nil {
+			out = &model.WaitStates{}
+		}
+		ws := s.WaitStates
+		blkio = append(blkio, ws.BlkioDelayMS)
+		out.RunningPolls += ws.RunningPolls
+		out.DiskPolls += ws.DiskPolls
+		out.SleepPolls += ws.SleepPolls
+		for w, n := range ws.Wchan {
+			if out.Wchan == nil {
+				out.Wchan = map[string]int{}
+			}
+			out.Wchan[w] += n
+		}
+	}
+	if out != nil {
+		out.BlkioDelayMS = stats.Median(blkio)
+	}
+	return out
+}
+
+func medianIO(samples []model.Sample) *model.IOCounters {
+	var withIO []model.Sample
+	for _, s := range samples {
+		if s.IO != nil {
+			withIO = append(withIO, s)
+		}
+	}
+	if len(withIO) == 0 {
+		return nil
+	}
+	return &model.IOCounters{
+		ReadChars:  medianInt(withIO, func(s model.Sample) int64 { return s.IO.ReadChars }),
+		WriteChars: medianInt(withIO, func(s model.Sample) int64 { return s.IO.WriteChars }),
+		ReadCalls:  medianInt(withIO, func(s model.Sample) int64 { return s.IO.ReadCalls }),
+		WriteCalls: medianInt(withIO, func(s model.Sample) int64 { return s.IO.WriteCalls }),
+		ReadBytes:  medianInt(withIO, func(s model.Sample) int64 { return s.IO.ReadBytes }),
+		WriteBytes: medianInt(withIO, func(s model.Sample) int64 { return s.IO.WriteBytes }),
+	}
+}
+
+func medianPerf(samples []model.Sample) *model.PerfCounters {
+	var withPerf []model.Sample
+	for _, s := range samples {
+		if s.Perf != nil {
+			withPerf = append(withPerf, s)
+		}
+	}
+	if len(withPerf) == 0 {
+		return nil
+	}
+	first := withPerf[0].Perf
+	return &model.PerfCounters{
+		TaskClockMS:     stats.Median(sampleValues(withPerf, func(s model.Sample) float64 { return s.Perf.TaskClockMS })),
+		ContextSwitches: medianInt(withPerf, func(s model.Sample) int64 { return s.Perf.ContextSwitches }),
+		CPUMigrations:   medianInt(withPerf, func(s model.Sample) int64 { return s.Perf.CPUMigrations }),
+		PageFaults:      medianInt(withPerf, func(s model.Sample) int64 { return s.Perf.PageFaults }),
+		Cycles:          medianInt(withPerf, func(s model.Sample) int64 { return s.Perf.Cycles }),
+		Instructions:    medianInt(withPerf, func(s model.Sample) int64 { return s.Perf.Instructions }),
+		CacheMisses:     medianInt(withPerf, func(s model.Sample) int64 { return s.Perf.CacheMisses }),
+		IPC:             stats.Median(sampleValues(withPerf, func(s model.Sample) float64 { return s.Perf.IPC })),
+		UserOnly:        first.UserOnly,
+		Multiplexed:     first.Multiplexed,
+		Unavailable:     first.Unavailable,
+	}
+}
+
+func medianCgroup(samples []model.Sample) *model.CgroupStats {
+	var withCg []model.Sample
+	for _, s := range samples {
+		if s.Cgroup != nil {
+			withCg = append(withCg, s)
+		}
+	}
+	if len(withCg) == 0 {
+		return nil
+	}
+	ms := func(get func(*model.CgroupStats) float64) float64 {
+		return stats.Median(sampleValues(withCg, func(s model.Sample) float64 { return get(s.Cgroup) }))
+	}
+	n := func(get func(*model.CgroupStats) int64) int64 {
+		return medianInt(withCg, func(s model.Sample) int64 { return get(s.Cgroup) })
+	}
+	return &model.CgroupStats{
+		CPUUsageMS:       ms(func(c *model.CgroupStats) float64 { return c.CPUUsageMS }),
+		CPUUserMS:        ms(func(c *model.CgroupStats) float64 { return c.CPUUserMS }),
+		CPUSystemMS:      ms(func(c *model.CgroupStats) float64 { return c.CPUSystemMS }),
+		ThrottledPeriods: n(func(c *model.CgroupStats) int64 { return c.ThrottledPeriods }),
+		ThrottledMS:      ms(func(c *model.CgroupStats) float64 { return c.ThrottledMS }),
+		MemoryPeakBytes:  n(func(c *model.CgroupStats) int64 { return c.MemoryPeakBytes }),
+		MemoryHigh:       n(func(c *model.CgroupStats) int64 { return c.MemoryHigh }),
+		MemoryMax:        n(func(c *model.CgroupStats) int64 { return c.MemoryMax }),
+		OOMKills:         n(func(c *model.CgroupStats) int64 { return c.OOMKills }),
+		IOReadBytes:      n(func(c *model.CgroupStats) int64 { return c.IOReadBytes }),
+		IOWriteBytes:     n(func(c *model.CgroupStats) int64 { return c.IOWriteBytes }),
+		IOReadOps:        n(func(c *model.CgroupStats) int64 { return c.IOReadOps }),
+		IOWriteOps:       n(func(c *model.CgroupStats) int64 { return c.IOWriteOps }),
+		PidsPeak:         n(func(c *model.CgroupStats) int64 { return c.PidsPeak }),
+		Missing:          withCg[0].Cgroup.Missing,
+		MissingReason:    withCg[0].Cgroup.MissingReason,
+	}
+}
+
+// mergeLimits keeps the limits of the first sample with any and the median
+// throttling and reclaim. OOM kills are summed: one in any sample matters.
+func mergeLimits(samples []model.Sample) *model.CgroupLimits {
+	var withLimits []model.Sample
+	for _, s := range samples {
+		if s.Limits != nil {
+			withLimits = append(withLimits, s)
+		}
+	}
+	if len(withLimits) == 0 {
+		return nil
+	}
+	out := *withLimits[0].Limits
+	// Samples without a Limits entry saw no throttling or reclaim at all.
+	for len(withLimits) < len(samples) {
+		withLimits = append(withLimits, model.Sample{Limits: &model.CgroupLimits{}})
+	}
+	n := func(get func(*model.CgroupLimits) int64) int64 {
+		return medianInt(withLimits, func(s model.Sample) int64 { return get(s.Limits) })
+	}
+	out.Periods = n(func(l *model.CgroupLimits) int64 { return l.Periods })
+	out.ThrottledPeriods = n(func(l *model.CgroupLimits) int64 { return l.ThrottledPeriods })
+	out.ThrottledMS = stats.Median(sampleValues(withLimits, func(s model.Sample) float64 { return s.Limits.ThrottledMS }))
+	out.MemoryHighEvents = n(func(l *model.CgroupLimits) int64 { return l.MemoryHighEvents })
+	out.MemoryMaxEvents = n(func(l *model.CgroupLimits) int64 { return l.MemoryMaxEvents })
+	out.OOMKills = 0
+	for _, s := range withLimits {
+		out.OOMKills += s.Limits.OOMKills
+	}
+	return &out
+}
+
+// peakMemory keeps the composition of the sample with the highest peak, as
+// max RSS does, and the most swap seen in any.
+func peakMemory(samples []model.Sample) *model.MemoryComposition {
+	var out *model.MemoryComposition
+	var swap int64
+	for _, s := range samples {
+		if s.Memory == nil {
+			continue
+		}
+		swap = max(swap, s.Memory.SwapKB)
+		if out == nil || s.Memory.PeakKB > out.PeakKB {
+			m := *s.Memory
+			out = &m
+		}
+	}
+	if out != nil {
+		out.SwapKB = swap
+	}
+	return out
+}
+
+// medianThreads keeps the thread table of the median-wall sample.
+func medianThreads(samples []model.Sample) *model.ThreadStats {
+	var with []model.Sample
+	for _, s := range samples {
+		if s.Threads != nil {
+			with = append(with, s)
+		}
+	}
+	if len(with) == 0 {
+		return nil
+	}
+	sort.SliceStable(with, func(i, j int) bool { return with[i].WallMS < with[j].WallMS })
+	return with[(len(with)-1)/2].Threads
+}
+
+// medianOutput takes the per-stream median across samples.
+func medianOutput(samples []model.Sample) *model.Output {
+	var with []model.Sample
+	for _, s := range samples {
+		if s.Output != nil {
+			with = append(with, s)
+		}
+	}
+	if len(with) == 0 {
+		return nil
+	}
+	stream := func(get func(*model.Output) model.StreamStats) model.StreamStats {
+		return model.StreamStats{
+			Bytes:     medianInt(with, func(s model.Sample) int64 { return get(s.Output).Bytes }),
+			Lines:     medianInt(with, func(s model.Sample) int64 { return get(s.Output).Lines }),
+			BlockedMS: stats.Median(sampleValues(with, func(s model.Sample) float64 { return get(s.Output).BlockedMS })),
+		}
+	}
+	return &model.Output{
+		Mode:     with[0].Output.Mode,
+		Terminal: with[0].Output.Terminal,
+		Stdout:   stream(func(o *model.Output) model.StreamStats { return o.Stdout }),
+		Stderr:   stream(func(o *model.Output) model.StreamStats { return o.Stderr }),
+	}
+}
+
+// completedSamples drops the samples that were cut short.
+func completedSamples(samples []model.Sample) []model.Sample {
+	var out []model.Sample
+	for _, s := range samples {
+		if !s.CutShort() {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// pressureDelta converts two PSI snapshots into stall time during a sample.
+func pressureDelta(before, after procfs.SystemPressure) *model.Pressure {
+	ms := func(b, a uint64) float64 {
+		if a < b {
+			return 0
+		}
+		return float64(a-b) / 1000
+	}
+	return &model.Pressure{
+		CPUSomeMS:    ms(before.CPU.SomeUS, after.CPU.SomeUS),
+		MemorySomeMS: ms(before.Memory.SomeUS, after.Memory.SomeUS),
+		MemoryFullMS: ms(before.Memory.FullUS, after.Memory.FullUS),
+		IOSomeMS:     ms(before.IO.SomeUS, after.IO.SomeUS),
+		IOFullMS:     ms(before.IO.FullUS, after.IO.FullUS),
+	}
+}
+
+// medianPressure takes the per-counter median across samples that could read
+// /proc/pressure.
+func medianPressure(samples []model.Sample) *model.Pressure {
+	var withPSI []model.Sample
+	for _, s := range samples {
+		if s.Pressure != nil {
+			withPSI = append(withPSI, s)
+		}
+	}
+	if len(withPSI) == 0 {
+		return nil
+	}
+	ms := func(get func(*model.Pressure) float64) float64 {
+		return stats.Median(sampleValues(withPSI, func(s model.Sample) float64 { return get(s.Pressure) }))
+	}
+	return &model.Pressure{
+		CPUSomeMS:    ms(func(p *model.Pressure) float64 { return p.CPUSomeMS }),
+		MemorySomeMS: ms(func(p *model.Pressure) float64 { return p.MemorySomeMS }),
+		MemoryFullMS: ms(func(p *model.Pressure) float64 { return p.MemoryFullMS }),
+		IOSomeMS:     ms(func(p *model.Pressure) float64 { return p.IOSomeMS }),
+		IOFullMS:     ms(func(p *model.Pressure) float64 { return p.IOFullMS }),
+	}
+}
+
+// mergeSyscalls takes the per-syscall median across traced samples, counting
+// a syscall missing from a sample as zero calls. Max latency is the overall max.
+func mergeSyscalls(samples []model.Sample) []model.SyscallStat {
+	var traced []model.Sample
+	var names []string
+	seen := map[string]bool{}
+	for _, s := range samples {
+		if s.Syscalls == nil {
+			continue
+		}
+		traced = append(traced, s)
+		for _, st := range s.Syscalls {
+			if !seen[st.Name] {
+				seen[st.Name] = true
+				names = append(names, st.Name)
+			}
+		}
+	}
+	if len(traced) == 0 {
+		return nil
+	}
+	if len(traced) == 1 {
+		return traced[0].Syscalls
+	}
+
+	out := make([]model.SyscallStat, 0, len(names))
+	for _, name := range names {
+		per := make([]model.SyscallStat, len(traced))
+		for i, s := range traced {
+			for _, st := range s.Syscalls {
+				if st.Name == name {
+					per[i] = st
+				}
+			}
+		}
+		merged := model.SyscallStat{Name: name}
+		merged.Count = medianStat(per, func(st model.SyscallStat) float64 { return float64(st.Count) })
+		merged.Errors = medianStat(per, func(st model.SyscallStat) float64 { return float64(st.Errors) })
+		merged.Timeouts = medianStat(per, func(st model.SyscallStat) float64 { return float64(st.Timeouts) })
+		merged.TotalMS = stats.Median(statValues(per, func(st model.SyscallStat) float64 { return st.TotalMS }))
+		merged.TimeoutMS = stats.Median(statValues(per, func(st model.SyscallStat) float64 { return st.TimeoutMS }))
+		for _, st := range per {
+			merged.MaxMS = max(merged.MaxMS, st.MaxMS)
+		}
+		out = append(out, merged)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].TotalMS > out[j].TotalMS })
+	return out
+}
+
+// mergeNetwork keeps the destinations of the median-wall traced sample. Unlike
+// syscall counts, per-endpoint numbers from different samples rarely line up
+// (DNS answers and connection reuse vary), so they are not mixed.
+func mergeNetwork(samples []model.Sample) []model.NetDest {
+	var traced []model.Sample
+	for _, s := range samples {
+		if s.Network != nil {
+			traced = append(traced, s)
+		}
+	}
+	if len(traced) == 0 {
+		return nil
+	}
+	sort.SliceStable(traced, func(i, j int) bool { return traced[i].WallMS < traced[j].WallMS })
+	return traced[(len(traced)-1)/2].Network
+}
+
+// mergeFiles keeps the path table of the median-wall traced sample, for the
+// same reason as mergeNetwork, preferring samples that were not cut short.
+// All samples keep their totals but drop their path tables, which can be
+// large and would otherwise be stored twice.
+func mergeFiles(samples []model.Sample) *model.FileTrace {
+	var traced, completed []int
+	for i, s := range samples {
+		if s.Files != nil {
+			traced = append(traced, i)
+			if !s.CutShort() {
+				completed = append(completed, i)
+			}
+		}
+	}
+	if len(traced) == 0 {
+		return nil
+	}
+	pick := traced
+	if len(completed) > 0 {
+		pick = completed
+	}
+	sort.SliceStable(pick, func(i, j int) bool { return samples[pick[i]].WallMS < samples[pick[j]].WallMS })
+	merged := samples[pick[(len(pick)-1)/2]].Files
+	for _, i := range traced {
+		totals := *samples[i].Files
+		totals.Top = nil
+		samples[i].Files = &totals
+	}
+	return merged
+}
+
+func statValues(per []model.SyscallStat, get func(model.SyscallStat) float64) []float64 {
+	out := make([]float64, 0, len(per))
+	for _, st := range per {
+		out = append(out, get(st))
+	}
+	return out
+}
+
+func medianStat(per []model.SyscallStat, get func(model.SyscallStat) float64) int64 {
+	return int64(math.Round(stats.Median(statValues(per, get))))
+}
+
+func sampleValues(samples []model.Sample, get func(model.Sample) float64) []float64 {
+	out := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		out = append(out, get(s))
+	}
+	return out
+}
+
+// medianInt aggregates an integer counter across samples, rounded to the nearest count.
+func medianInt(samples []model.Sample, get func(model.Sample) int64) int64 {
+	vals := sampleValues(samples, func(s model.Sample) float64 { return float64(get(s)) })
+	return int64(math.Round(stats.Median(vals)))
+}
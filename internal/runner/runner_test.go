@@ -1,12 +1,22 @@
 package runner
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/cgroup"
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
 )
 
 func TestRunnerSleep(t *testing.T) {
@@ -18,6 +28,12 @@ func TestRunnerSleep(t *testing.T) {
 	if res.WallMS < 150 {
 		t.Fatalf("wall too small: %.2f", res.WallMS)
 	}
+	if _, err := os.Stat("/proc/pressure/io"); err == nil && res.Pressure == nil {
+		t.Fatalf("expected PSI deltas when /proc/pressure is readable")
+	}
+	if runtime.GOOS == "linux" && (res.Memory == nil || res.Memory.PeakKB == 0) {
+		t.Fatalf("expected a memory composition from smaps_rollup, got %+v", res.Memory)
+	}
 }
 
 func TestRunnerCPUBound(t *testing.T) {
@@ -32,6 +48,9 @@ func TestRunnerCPUBound(t *testing.T) {
 	if res.CPURatio < 0.50 {
 		t.Fatalf("expected cpu heavy ratio, got %.2f", res.CPURatio)
 	}
+	if runtime.GOOS == "linux" && (res.Threads == nil || res.Threads.Busy == 0 || res.Threads.Top[0].CPUMS <= 0) {
+		t.Fatalf("expected per-thread CPU, got %+v", res.Threads)
+	}
 }
 
 func TestRunnerStderrExit(t *testing.T) {
@@ -48,6 +67,677 @@ func TestRunnerStderrExit(t *testing.T) {
 	}
 }
 
+func TestRunnerPerfCounters(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("perf events are Linux only")
+	}
+	bin := buildHelper(t, "cpuburner")
+	res, err := Execute(testContext(t), Options{Command: []string{bin}})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if res.Perf == nil {
+		t.Skip("perf_event_open is not permitted here")
+	}
+	// task-clock and rusage measure the same CPU time in different ways.
+	cpu := res.UserMS + res.SysMS
+	if res.Perf.TaskClockMS < cpu*0.8 || res.Perf.TaskClockMS > cpu*1.2+10 {
+		t.Fatalf("task-clock %.1fms does not match rusage cpu %.1fms", res.Perf.TaskClockMS, cpu)
+	}
+	if res.Perf.PageFaults == 0 {
+		t.Fatalf("expected page faults, got %+v", res.Perf)
+	}
+	if res.Perf.IPC == 0 && len(res.Perf.Unavailable) == 0 {
+		t.Fatalf("hardware counters neither read nor reported missing: %+v", res.Perf)
+	}
+}
+
+func TestMeasureEnvironment(t *testing.T) {
+	start := &envSnapshot{
+		at:    time.Unix(100, 0),
+		load:  0.1,
+		cpu:   procfs.CPUTimes{User: 1000, Idle: 1000},
+		procs: map[procKey]procTicks{{10, 1}: {"indexer", 500}, {11, 1}: {"idle", 50}},
+	}
+	end := &envSnapshot{
+		at:   time.Unix(102, 0),
+		load: 0.9,
+		cpu:  procfs.CPUTimes{User: 1300, Idle: 1100, Steal: 0},
+		// pid 11 was replaced by a new process, pid 12 is the measured command.
+		procs: map[procKey]procTicks{{10, 1}: {"indexer", 600}, {11, 7}: {"other", 400}, {12, 5}: {"cmd", 200}},
+	}
+	env := measureEnvironment(start, end, 1)
+	if len(env.BusyProcs) != 1 || env.BusyProcs[0].Comm != "indexer" || env.BusyProcs[0].CPUPercent != 50 {
+		t.Fatalf("unexpected busy processes: %+v", env.BusyProcs)
+	}
+	if env.Quality >= 70 || len(env.Issues) == 0 {
+		t.Fatalf("expected poor quality, got %d %v", env.Quality, env.Issues)
+	}
+	if measureEnvironment(nil, end, 1) != nil {
+		t.Fatalf("expected nil without a start snapshot")
+	}
+}
+
+func TestScoreEnvironmentGovernor(t *testing.T) {
+	for gov, want := range map[string]int{"performance": 100, "schedutil": 100, "ondemand": 100, "powersave": 90, "conservative": 90} {
+		env := &model.Environment{Governor: gov}
+		scoreEnvironment(env, 1)
+		if env.Quality != want {
+			t.Fatalf("governor %s: quality %d, want %d (%v)", gov, env.Quality, want, env.Issues)
+		}
+	}
+}
+
+func TestRunnerStdoutCapture(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	res, err := Execute(testContext(t), Options{Command: []string{"sh", "-c", "echo one; echo two; echo err >&2"}, Stdout: OutputCapture})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	o := res.Output
+	if o == nil || o.Mode != "capture" || o.Stdout.Bytes != 8 || o.Stdout.Lines != 2 || o.Stderr.Lines != 1 {
+		t.Fatalf("unexpected output counts: %+v", o)
+	}
+	if res.StdoutTail != "one\ntwo\n" {
+		t.Fatalf("expected captured stdout, got %q", res.StdoutTail)
+	}
+}
+
+func TestRunnerStdoutInherit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	saved := os.Stdout
+	os.Stdout = f
+	res, err := Execute(testContext(t), Options{Command: []string{"sh", "-c", "echo hello"}, Stdout: OutputInherit})
+	os.Stdout = saved
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	data, _ := os.ReadFile(f.Name())
+	if string(data) != "hello\n" {
+		t.Fatalf("stdout was not passed through: %q", data)
+	}
+	if o := res.Output; o == nil || o.Mode != "inherit" || o.Terminal || o.Stdout.Bytes != 6 || o.Stdout.Lines != 1 {
+		t.Fatalf("inherited stdout not counted: %+v", o)
+	}
+}
+
+func TestRunnerStdoutTerminal(t *testing.T) {
+	// Stand in for a terminal with a pty of our own.
+	var out bytes.Buffer
+	term, err := newPtyRelay(os.Stdin, &out)
+	if err != nil {
+		t.Skipf("no pty: %v", err)
+	}
+	saved := os.Stdout
+	os.Stdout = term.tty
+	res, err := Execute(testContext(t), Options{Command: []string{"sh", "-c", "test -t 1 && echo tty"}, Stdout: OutputInherit})
+	os.Stdout = saved
+	term.finish()
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "tty" {
+		t.Fatalf("command should still see a terminal, printed %q", got)
+	}
+	if o := res.Output; o == nil || !o.Terminal || o.Stdout.Lines != 1 {
+		t.Fatalf("output through the pty not counted: %+v", o)
+	}
+}
+
+func TestIsTerminalDevNull(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no /dev/null")
+	}
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if isTerminal(f) {
+		t.Fatal("/dev/null is a character device but not a terminal")
+	}
+}
+
+func TestRunnerWarmup(t *testing.T) {
+	bin := buildHelper(t, "failer")
+	res, err := Execute(testContext(t), Options{Command: []string{bin}, Repeat: 2, Warmup: 1})
+	if err != nil && !isExitCodeError(err) {
+		t.Fatalf("execute: %v", err)
+	}
+	if len(res.Warmup) != 1 || len(res.RawSamples) != 2 {
+		t.Fatalf("expected 1 warmup and 2 measured samples, got %d and %d", len(res.Warmup), len(res.RawSamples))
+	}
+}
+
+func TestRunnerReapsEarlierLeftovers(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs a subreaper")
+	}
+	// The first sample's leftover exits during the cooldown and must not stay
+	// a zombie once the second sample started; the second one's is still
+	// running when the run ends.
+	script := "if [ -e seen ]; then sleep 1 & else touch seen; sleep 0.1 & fi"
+	res, err := Execute(testContext(t), Options{Command: []string{"sh", "-c", script}, CWD: t.TempDir(), Repeat: 2, Cooldown: 400 * time.Millisecond, Leftovers: LeftoversReport})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	first := res.RawSamples[0].Leftovers
+	if len(first) != 1 {
+		t.Fatalf("expected one leftover from the first sample, got %+v", first)
+	}
+	if st, err := procfs.ReadStat(first[0].PID); err == nil && st.State == 'Z' {
+		t.Fatalf("leftover %d from the first sample was never reaped", first[0].PID)
+	}
+}
+
+func TestRunnerTraceSkipsEarlierLeftovers(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("ptrace tracing is Linux only")
+	}
+	// The first sample's leftover exits while the second sample is traced.
+	// The trace loop reaps it but must neither track it nor charge it to the
+	// second sample.
+	script := "if [ -e seen ]; then sleep 1; else touch seen; sleep 0.3 & fi"
+	res, err := Execute(testContext(t), Options{Command: []string{"sh", "-c", script}, CWD: t.TempDir(), Repeat: 2, TraceProcs: true, Leftovers: LeftoversReport})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	first := res.RawSamples[0].Leftovers
+	if len(first) != 1 {
+		t.Fatalf("expected one leftover from the first sample, got %+v", first)
+	}
+	second := res.RawSamples[1]
+	if len(second.Leftovers) != 0 {
+		t.Fatalf("earlier leftover charged to the second sample: %+v", second.Leftovers)
+	}
+	for _, n := range second.ProcTree {
+		if n.PID == first[0].PID {
+			t.Fatalf("earlier leftover %d traced in the second sample", n.PID)
+		}
+	}
+	if st, err := procfs.ReadStat(first[0].PID); err == nil && st.State == 'Z' {
+		t.Fatalf("leftover %d from the first sample was never reaped", first[0].PID)
+	}
+}
+
+func TestRunnerHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	hooks := Hooks{
+		Setup:    "echo setup >> log",
+		Prepare:  "echo prepare >> log",
+		Cleanup:  "echo cleanup >> log",
+		Teardown: "echo teardown >> log",
+	}
+	res, err := Execute(testContext(t), Options{Command: []string{"sh", "-c", "echo run >> log"}, CWD: dir, Repeat: 2, Hooks: hooks})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	data, _ := os.ReadFile(log)
+	want := "setup\nprepare\nrun\ncleanup\nprepare\nrun\ncleanup\nteardown\n"
+	if string(data) != want {
+		t.Fatalf("unexpected hook order:\n%s", data)
+	}
+	if len(res.Hooks) != 2 || len(res.RawSamples[0].Hooks) != 2 || res.RawSamples[0].Hooks[0].Kind != "prepare" {
+		t.Fatalf("hooks not recorded: %+v %+v", res.Hooks, res.RawSamples[0].Hooks)
+	}
+
+	os.Remove(log)
+	hooks.Prepare = "echo broken >&2; exit 3"
+	_, err = Execute(testContext(t), Options{Command: []string{"true"}, CWD: dir, Hooks: hooks})
+	if err == nil || !strings.Contains(err.Error(), "prepare hook") || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("expected a prepare hook error, got %v", err)
+	}
+	data, _ = os.ReadFile(log)
+	if string(data) != "setup\nteardown\n" {
+		t.Fatalf("teardown should still run after an abort:\n%s", data)
+	}
+}
+
+func TestRunnerHookStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	// A hook reading our stdin would wait on it forever, or be stopped by
+	// SIGTTIN if it were the terminal.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	saved := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = saved }()
+	ctx, cancel := context.WithTimeout(testContext(t), 5*time.Second)
+	defer cancel()
+	_, err = Execute(ctx, Options{Command: []string{"true"}, Hooks: Hooks{Prepare: "cat >/dev/null"}})
+	if err != nil {
+		t.Fatalf("a hook reading stdin should get EOF: %v", err)
+	}
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	// The shell ignores SIGTERM, so only the SIGKILL after the grace period
+	// stops it.
+	res, err := Execute(testContext(t), Options{
+		Command:   []string{"sh", "-c", "trap '' TERM; while :; do sleep 0.05; done"},
+		Repeat:    2,
+		Timeout:   200 * time.Millisecond,
+		KillGrace: 100 * time.Millisecond,
+		Leftovers: LeftoversKill,
+	})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if res.TimedOut != 2 || !res.RawSamples[0].TimedOut || res.RawSamples[0].Signal != "killed" {
+		t.Fatalf("expected two killed samples, got timed_out=%d %+v", res.TimedOut, res.RawSamples[0])
+	}
+	if res.WallMS < 300 || res.WallMS > 2000 {
+		t.Fatalf("expected the grace period before SIGKILL, wall %.1fms", res.WallMS)
+	}
+
+	// Only the first sample hangs; the stats come from the others.
+	res, err = Execute(testContext(t), Options{
+		Command: []string{"sh", "-c", "if [ -e seen ]; then exit 0; fi; touch seen; sleep 5"},
+		CWD:     t.TempDir(),
+		Repeat:  3,
+		Timeout: 300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if res.TimedOut != 1 || len(res.RawSamples) != 3 || res.Repeat.P90WallMS >= 300 {
+		t.Fatalf("expected the timed-out sample out of the stats, got timed_out=%d p90 %.1fms", res.TimedOut, res.Repeat.P90WallMS)
+	}
+}
+
+func TestRunnerForwardsInterrupt(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("finds the child through /proc")
+	}
+	go func() {
+		// Signal only once the sample runs, so the forwarder is installed.
+		for i := 0; i < 200 && len(procfs.Children(os.Getpid())) == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		self, _ := os.FindProcess(os.Getpid())
+		_ = self.Signal(os.Interrupt)
+	}()
+	res, err := Execute(testContext(t), Options{Command: []string{"sh", "-c", "sleep 5 & sleep 5; wait"}, Repeat: 3})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if !res.Interrupted || len(res.RawSamples) != 1 {
+		t.Fatalf("expected one interrupted sample, got interrupted=%v samples=%d", res.Interrupted, len(res.RawSamples))
+	}
+	s := res.RawSamples[0]
+	if !s.Interrupted || len(s.Forwarded) != 1 || s.Forwarded[0] != "interrupt" || s.Signal != "interrupt" {
+		t.Fatalf("expected SIGINT forwarded to the sample, got %+v", s)
+	}
+	for _, p := range res.Leftovers {
+		if p.State == "running" {
+			t.Fatalf("the sample's group should be killed and reaped: %+v", res.Leftovers)
+		}
+	}
+
+	// A second, interrupted sample is kept but stays out of the stats.
+	dir := t.TempDir()
+	go func() {
+		for i := 0; i < 500; i++ {
+			time.Sleep(10 * time.Millisecond)
+			if _, err := os.Stat(filepath.Join(dir, "seen")); err != nil {
+				continue
+			}
+			for _, pid := range procfs.Children(os.Getpid()) {
+				for _, child := range procfs.Children(pid) {
+					if st, err := procfs.ReadStat(child); err == nil && st.Comm == "sleep" {
+						self, _ := os.FindProcess(os.Getpid())
+						_ = self.Signal(os.Interrupt)
+						return
+					}
+				}
+			}
+		}
+	}()
+	res, err = Execute(testContext(t), Options{Command: []string{"sh", "-c", "if [ -e seen ]; then sleep 5; else touch seen; fi"}, CWD: dir, Repeat: 3})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if len(res.RawSamples) != 2 || !res.RawSamples[1].Interrupted {
+		t.Fatalf("expected the second sample interrupted, got %d samples", len(res.RawSamples))
+	}
+	if res.WallMS != res.RawSamples[0].WallMS || res.Repeat.P90WallMS != res.RawSamples[0].WallMS {
+		t.Fatalf("interrupted sample counted in the stats: wall %.1fms p90 %.1fms, samples %.1fms %.1fms", res.WallMS, res.Repeat.P90WallMS, res.RawSamples[0].WallMS, res.RawSamples[1].WallMS)
+	}
+}
+
+func TestRunnerCancelKeepsSamples(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	// The first sample is quick; the second sleeps until ctx is cancelled.
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(testContext(t))
+	defer cancel()
+	time.AfterFunc(500*time.Millisecond, cancel)
+	res, err := Execute(ctx, Options{Command: []string{"sh", "-c", "test -f ran && exec sleep 10; touch ran"}, CWD: dir, Repeat: 3})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancellation, got %v", err)
+	}
+	if len(res.RawSamples) != 2 || res.RawSamples[0].Interrupted || !res.RawSamples[1].Interrupted || !res.Interrupted {
+		t.Fatalf("expected the finished and the cut sample back, got %d: %+v", len(res.RawSamples), res.RawSamples)
+	}
+	if res.WallMS >= 500 {
+		t.Fatalf("the cut sample should stay out of the stats, wall %.1fms", res.WallMS)
+	}
+}
+
+func TestRunnerInterruptBetweenSamples(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("finds the hook through /proc")
+	}
+	// The second prepare hook hangs; Ctrl-C there must end the run with the
+	// first sample kept instead of aborting it.
+	dir := t.TempDir()
+	prepare := "[ -e ran ] && sleep 5; true"
+	go func() {
+		for i := 0; i < 500; i++ {
+			time.Sleep(10 * time.Millisecond)
+			if _, err := os.Stat(filepath.Join(dir, "ran")); err != nil {
+				continue
+			}
+			for _, pid := range procfs.Children(os.Getpid()) {
+				if strings.Contains(strings.Join(procfs.ReadCmdline(pid), " "), prepare) {
+					self, _ := os.FindProcess(os.Getpid())
+					_ = self.Signal(os.Interrupt)
+					return
+				}
+			}
+		}
+	}()
+	start := time.Now()
+	res, err := Execute(testContext(t), Options{Command: []string{"touch", "ran"}, CWD: dir, Repeat: 3, Hooks: Hooks{Prepare: prepare}})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if !res.Interrupted || len(res.RawSamples) != 1 || res.RawSamples[0].Interrupted {
+		t.Fatalf("expected the first sample kept, got interrupted=%v samples=%d", res.Interrupted, len(res.RawSamples))
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Fatalf("the hook was not stopped: took %s", time.Since(start))
+	}
+}
+
+func TestRunnerMaxTotalTime(t *testing.T) {
+	bin := buildHelper(t, "sleeper")
+	res, err := Execute(testContext(t), Options{Command: []string{bin}, Repeat: 50, MaxTotalTime: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if res.Repeat == nil || res.Repeat.Count >= 50 || res.Repeat.Count != len(res.RawSamples) || res.Repeat.Requested != 50 {
+		t.Fatalf("expected an early stop, got %+v", res.Repeat)
+	}
+}
+
+func TestRunnerWaitIdleCooldown(t *testing.T) {
+	bin := buildHelper(t, "failer")
+	start := time.Now()
+	res, err := Execute(testContext(t), Options{
+		Command:  []string{bin},
+		Repeat:   2,
+		WaitIdle: &IdleWait{MaxLoad: 1000, MaxWait: time.Second},
+		Cooldown: 200 * time.Millisecond,
+	})
+	if err != nil && !isExitCodeError(err) {
+		t.Fatalf("execute: %v", err)
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Fatalf("cooldown was skipped")
+	}
+	if res.Idle == nil || res.Idle.CooldownMS != 200 || res.Idle.TimedOut != 0 || res.Idle.WaitedMS > 500 {
+		t.Fatalf("unexpected idle summary: %+v", res.Idle)
+	}
+}
+
+func TestRunnerCgroup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("cgroups are Linux only")
+	}
+	if _, err := cgroup.Setup(); err != nil {
+		t.Skipf("no delegated cgroup v2 here: %v", err)
+	}
+	bin := buildHelper(t, "cpuburner")
+	res, err := Execute(testContext(t), Options{Command: []string{bin}, Repeat: 2, Cgroup: true})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if res.Cgroup == nil || len(res.RawSamples) != 2 || res.RawSamples[1].Cgroup == nil {
+		t.Fatalf("expected cgroup stats per sample and merged, got %+v", res.Cgroup)
+	}
+	cpu := res.UserMS + res.SysMS
+	if res.Cgroup.CPUUsageMS < cpu*0.8 {
+		t.Fatalf("cgroup cpu %.1fms is well below rusage %.1fms", res.Cgroup.CPUUsageMS, cpu)
+	}
+}
+
+func TestRunnerSampleTimeline(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("timeline sampling needs /proc")
+	}
+	bin := buildHelper(t, "sleeper")
+	res, err := Execute(testContext(t), Options{Command: []string{bin}, SampleInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if len(res.RawSamples) != 1 || len(res.RawSamples[0].Timeline) == 0 {
+		t.Fatalf("expected timeline points")
+	}
+	if p := res.RawSamples[0].Timeline[0]; p.Procs == 0 || p.RSSKB == 0 || p.OpenFDs != 0 {
+		t.Fatalf("expected live process data without fd counts, got %+v", p)
+	}
+
+	res, err = Execute(testContext(t), Options{Command: []string{bin}, SampleInterval: 20 * time.Millisecond, SampleDetail: true})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if p := res.RawSamples[0].Timeline[0]; p.OpenFDs == 0 {
+		t.Fatalf("expected open fds with SampleDetail, got %+v", p)
+	}
+}
+
+func TestRunnerIOCountersBeforeReap(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("/proc/<pid>/io is Linux only")
+	}
+	bin := buildHelper(t, "writer")
+	target := filepath.Join(t.TempDir(), "out")
+	res, err := Execute(testContext(t), Options{Command: []string{bin, target}})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if res.IO == nil {
+		t.Fatalf("expected io counters")
+	}
+	if res.IO.WriteChars < 1<<20 {
+		t.Fatalf("wchar = %d, want >= 1MiB", res.IO.WriteChars)
+	}
+}
+
+func TestRunnerLeftoverPolicies(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("child subreaper is Linux only")
+	}
+	bin := buildHelper(t, "daemonizer")
+
+	res, err := Execute(testContext(t), Options{Command: []string{bin}, Leftovers: LeftoversWait})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if len(res.Leftovers) != 1 || res.Leftovers[0].State != "waited" {
+		t.Fatalf("expected one waited leftover, got %+v", res.Leftovers)
+	}
+
+	res, err = Execute(testContext(t), Options{Command: []string{bin}, Leftovers: LeftoversKill})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if len(res.Leftovers) != 1 || res.Leftovers[0].State != "killed" {
+		t.Fatalf("expected one killed leftover, got %+v", res.Leftovers)
+	}
+}
+
+func TestRunnerTraceProcs(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("ptrace tracing is Linux only")
+	}
+	bin := buildHelper(t, "daemonizer")
+	res, err := Execute(testContext(t), Options{Command: []string{bin}, TraceProcs: true, Leftovers: LeftoversKill})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	// The Go runtime may fork a probe of its own, so look the child up by argv.
+	tree := res.RawSamples[0].ProcTree
+	var child *model.ProcNode
+	for i, n := range tree {
+		if len(n.Argv) == 2 && n.Argv[1] == "child" {
+			child = &tree[i]
+		}
+	}
+	if len(tree) < 2 || child == nil {
+		t.Fatalf("expected parent and exec'd child in the tree, got %+v", tree)
+	}
+	parent := tree[0]
+	if child.PPID != parent.PID || child.Exe != "daemonizer" {
+		t.Fatalf("unexpected child node %+v", child)
+	}
+	if !child.Detached || parent.EndMS <= parent.StartMS {
+		t.Fatalf("expected child detached after parent exit: %+v %+v", parent, child)
+	}
+	if len(res.Leftovers) != 1 || res.Leftovers[0].State != "killed" {
+		t.Fatalf("expected the detached child as leftover, got %+v (tree %+v)", res.Leftovers, tree)
+	}
+
+	bin = buildHelper(t, "failer")
+	res, err = Execute(testContext(t), Options{Command: []string{bin}, TraceProcs: true})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if res.ExitCode == 0 || res.StderrTail == "" {
+		t.Fatalf("expected exit code and stderr under tracing, got %d %q", res.ExitCode, res.StderrTail)
+	}
+}
+
+func TestRunnerSyscalls(t *testing.T) {
+	if runtime.GOOS != "linux" || (runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64") {
+		t.Skip("syscall tracing needs linux/amd64 or linux/arm64")
+	}
+	bin := buildHelper(t, "writer")
+	target := filepath.Join(t.TempDir(), "out")
+	res, err := Execute(testContext(t), Options{Command: []string{bin, target}, Syscalls: true})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	counts := map[string]int64{}
+	for _, st := range res.Syscalls {
+		counts[st.Name] = st.Count
+	}
+	if counts["write"] < 1 || counts["openat"] < 1 || counts["exit_group"] != 1 {
+		t.Fatalf("expected write, openat and one exit_group, got %+v", res.Syscalls)
+	}
+	if res.RawSamples[0].ProcTree != nil {
+		t.Fatalf("process tree should only be stored with TraceProcs")
+	}
+}
+
+func TestRunnerTraceFiles(t *testing.T) {
+	if runtime.GOOS != "linux" || (runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64") {
+		t.Skip("file tracing needs linux/amd64 or linux/arm64")
+	}
+	bin := buildHelper(t, "writer")
+	target := filepath.Join(t.TempDir(), "out")
+	res, err := Execute(testContext(t), Options{Command: []string{bin, target}, Repeat: 2, TraceFiles: true})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if res.Files == nil {
+		t.Fatalf("expected a file trace")
+	}
+	var found bool
+	for _, fa := range res.Files.Top {
+		if fa.Path == target {
+			found = fa.Opens >= 1
+		}
+	}
+	if !found {
+		t.Fatalf("expected %s to be opened, got %+v", target, res.Files.Top)
+	}
+	for _, s := range res.RawSamples {
+		if s.Files == nil || s.Files.Opens < 1 || s.Files.Top != nil {
+			t.Fatalf("samples should keep totals without the path table, got %+v", s.Files)
+		}
+	}
+}
+
+func TestRunnerTraceNet(t *testing.T) {
+	if runtime.GOOS != "linux" || (runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64") {
+		t.Skip("network tracing needs linux/amd64 or linux/arm64")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = bufio.NewReader(conn).ReadString('\n')
+				time.Sleep(300 * time.Millisecond)
+				_, _ = conn.Write([]byte("pong\n"))
+			}()
+		}
+	}()
+
+	bin := buildHelper(t, "netclient")
+	res, err := Execute(testContext(t), Options{Command: []string{bin, ln.Addr().String()}, TraceNet: true})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if res.ExitCode != 0 {
+		t.Fatalf("client failed: %s", res.StderrTail)
+	}
+	if len(res.Network) == 0 {
+		t.Fatalf("expected a network destination")
+	}
+	dest := res.Network[0]
+	if dest.Address != ln.Addr().String() || dest.Proto != "tcp" || dest.Connects != 1 {
+		t.Fatalf("unexpected destination %+v", dest)
+	}
+	if dest.RecvBlockedMS < 250 || dest.BytesIn != 5 || dest.BytesOut != 5 {
+		t.Fatalf("expected ~300ms blocked and 5 bytes each way, got %+v", dest)
+	}
+}
+
 func buildHelper(t *testing.T, name string) string {
 	t.Helper()
 	root := moduleRoot(t)
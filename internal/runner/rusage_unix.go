@@ -10,12 +10,16 @@ import (
 
 // childUsage extracts usage stats from a completed process using rusage.
 // ru_maxrss units differ by platform: on Linux it is in kilobytes, on macOS bytes.
+// Block counts are reported as the kernel hands them over (512-byte units on Linux).
 func childUsage(ps *os.ProcessState) (Usage, bool) {
 	ru, ok := ps.SysUsage().(*syscall.Rusage)
 	if !ok || ru == nil {
 		return Usage{MaxRSSUnit: "unknown"}, false
 	}
+	return usageFromRusage(ru), true
+}
 
+func usageFromRusage(ru *syscall.Rusage) Usage {
 	userMS := float64(ru.Utime.Sec)*1000 + float64(ru.Utime.Usec)/1000
 	sysMS := float64(ru.Stime.Sec)*1000 + float64(ru.Stime.Usec)/1000
 
@@ -25,9 +29,15 @@ func childUsage(ps *os.ProcessState) (Usage, bool) {
 	}
 
 	return Usage{
-		UserMS:     userMS,
-		SysMS:      sysMS,
-		MaxRSS:     ru.Maxrss,
-		MaxRSSUnit: unit,
-	}, true
+		UserMS:      userMS,
+		SysMS:       sysMS,
+		MaxRSS:      int64(ru.Maxrss),
+		MaxRSSUnit:  unit,
+		MinorFaults: int64(ru.Minflt),
+		MajorFaults: int64(ru.Majflt),
+		InBlock:     int64(ru.Inblock),
+		OutBlock:    int64(ru.Oublock),
+		VolCtxSw:    int64(ru.Nvcsw),
+		InvolCtxSw:  int64(ru.Nivcsw),
+	}
 }
@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"sort"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// threadTop caps how many threads a sample records by name.
+const threadTop = 10
+
+// procStats is what the /proc sampler gathered over one sample.
+type procStats struct {
+	Timeline    []model.TimelinePoint
+	RunDelayMS  float64
+	WaitStates  model.WaitStates
+	Memory      *model.MemoryComposition
+	Threads     []model.ThreadCPU
+	PeakThreads int
+}
+
+// threadStats ranks the threads the sampler saw by CPU time.
+func threadStats(threads []model.ThreadCPU, peak int, wallMS float64) *model.ThreadStats {
+	if len(threads) == 0 {
+		return nil
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		if threads[i].CPUMS != threads[j].CPUMS {
+			return threads[i].CPUMS > threads[j].CPUMS
+		}
+		return threads[i].TID < threads[j].TID
+	})
+	out := &model.ThreadStats{Seen: len(threads), Peak: max(peak, 1)}
+	for _, t := range threads {
+		out.CPUMS += t.CPUMS
+		if wallMS > 0 && t.CPUMS >= wallMS*0.05 {
+			out.Busy++
+		}
+	}
+	out.Top = threads[:min(len(threads), threadTop)]
+	return out
+}
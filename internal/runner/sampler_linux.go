@@ -0,0 +1,257 @@
+//go:build linux
+
+package runner
+
+import (
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
+)
+
+// procSampler polls /proc for the child and its descendants while the command
+// runs, so short spikes are visible even though rusage only reports totals.
+// Counters such as run-queue delay are always gathered; the timeline is only
+// kept when one was asked for, and its open-fd counts only with detail.
+type procSampler struct {
+	root     int
+	interval time.Duration
+	timeline bool
+	detail   bool
+	start    time.Time
+	lastPoll time.Time
+	prev     map[int]uint64
+	points   []model.TimelinePoint
+	runDelay map[int]uint64 // per thread, nanoseconds
+	blkio    map[int]uint64 // per thread, clock ticks
+	states   model.WaitStates
+	memory   model.MemoryComposition
+	lastMem  time.Time
+	threads  map[int]model.ThreadCPU // by tid
+	peakThr  int
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func startSampler(pid int, start time.Time, interval time.Duration, timeline, detail bool) *procSampler {
+	s := &procSampler{
+		root:     pid,
+		interval: interval,
+		timeline: timeline,
+		detail:   detail,
+		start:    start,
+		lastPoll: start,
+		prev:     map[int]uint64{},
+		runDelay: map[int]uint64{},
+		blkio:    map[int]uint64{},
+		threads:  map[int]model.ThreadCPU{},
+		states:   model.WaitStates{Wchan: map[string]int{}},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+func (s *procSampler) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.poll()
+		}
+	}
+}
+
+func (s *procSampler) poll() {
+	now := time.Now()
+	point := model.TimelinePoint{OffsetMS: durationMS(now.Sub(s.start))}
+	cur := make(map[int]uint64)
+	var ticks uint64
+	var tree treeState
+	// smaps_rollup walks every mapping under the mm lock, so it is read at
+	// most every defaultPollInterval however fast the timeline polls.
+	readMem := now.Sub(s.lastMem) >= defaultPollInterval
+	var mem model.MemoryComposition
+
+	for _, pid := range procfs.Tree(s.root) {
+		st, err := procfs.ReadStat(pid)
+		if err != nil {
+			continue
+		}
+		total := st.CPUTicks()
+		cur[pid] = total
+		if prev, ok := s.prev[pid]; !ok {
+			ticks += total
+		} else if total > prev {
+			ticks += total - prev
+		}
+
+		tree = max(tree, s.pollTasks(pid))
+		if readMem {
+			addRollup(&mem, pid)
+		}
+
+		point.Procs++
+		point.Threads += st.NumThreads
+		if !s.timeline {
+			continue
+		}
+		if status, err := procfs.ReadStatus(pid); err == nil {
+			point.RSSKB += status.VmRSSKB
+		}
+		if !s.detail {
+			// Listing every fd directory is the costliest read here.
+			continue
+		}
+		if fds, err := procfs.CountFDs(pid); err == nil {
+			point.OpenFDs += fds
+		}
+	}
+
+	if elapsed := now.Sub(s.lastPoll).Seconds(); elapsed > 0 {
+		point.CPUPercent = float64(ticks) / procfs.ClockTicks / elapsed * 100
+	}
+	switch tree {
+	case treeRunning:
+		s.states.RunningPolls++
+	case treeDisk:
+		s.states.DiskPolls++
+	case treeSleeping:
+		s.states.SleepPolls++
+	}
+	s.peakThr = max(s.peakThr, point.Threads)
+	if readMem {
+		s.lastMem = now
+		if mem.PeakKB > s.memory.PeakKB {
+			mem.AtMS = point.OffsetMS
+			mem.SwapKB = max(mem.SwapKB, s.memory.SwapKB)
+			s.memory = mem
+		} else {
+			s.memory.SwapKB = max(mem.SwapKB, s.memory.SwapKB)
+		}
+	}
+	s.prev = cur
+	s.lastPoll = now
+	if s.timeline && point.Procs > 0 {
+		s.points = append(s.points, point)
+	}
+}
+
+// addRollup adds one process's smaps_rollup to a poll's totals. Kernels
+// without the Pss_Anon split fall back to Anonymous for the heap and count
+// the rest of PSS as file-backed.
+func addRollup(mem *model.MemoryComposition, pid int) {
+	r, err := procfs.ReadRollup(pid)
+	if err != nil {
+		return
+	}
+	anon, file, shmem := r.PSSAnon, r.PSSFile, r.PSSShmem
+	if anon+file+shmem == 0 && r.PSS > 0 {
+		anon = min(r.Anon, r.PSS)
+		file = r.PSS - anon
+	}
+	swap := r.SwapPSS
+	if swap == 0 {
+		swap = r.Swap
+	}
+	mem.PeakKB += anon + file + shmem
+	mem.AnonKB += anon
+	mem.FileKB += file
+	mem.ShmemKB += shmem
+	mem.SwapKB += swap
+}
+
+// treeState orders thread states so the busiest one wins for a poll.
+type treeState int
+
+const (
+	treeUnseen treeState = iota
+	treeSleeping
+	treeDisk
+	treeRunning
+)
+
+// pollTasks reads per-thread schedstat, state, and wchan. Counters only grow,
+// so the last value seen for each thread is its total so far.
+func (s *procSampler) pollTasks(pid int) treeState {
+	tids, err := procfs.Tasks(pid)
+	if err != nil {
+		return treeUnseen
+	}
+	state := treeUnseen
+	for _, tid := range tids {
+		if st, err := procfs.ReadTaskSchedstat(pid, tid); err == nil && st.RunDelayNS > s.runDelay[tid] {
+			s.runDelay[tid] = st.RunDelayNS
+		}
+		st, err := procfs.ReadTaskStat(pid, tid)
+		if err != nil {
+			continue
+		}
+		if st.BlkioTicks > s.blkio[tid] {
+			s.blkio[tid] = st.BlkioTicks
+		}
+		s.threads[tid] = model.ThreadCPU{PID: pid, TID: tid, Comm: st.Comm, CPUMS: float64(st.CPUTicks()) * 1000 / procfs.ClockTicks}
+		switch st.State {
+		case 'R':
+			state = max(state, treeRunning)
+		case 'D':
+			state = max(state, treeDisk)
+			s.countWchan(pid, tid)
+		case 'S':
+			state = max(state, treeSleeping)
+			s.countWchan(pid, tid)
+		}
+	}
+	return state
+}
+
+func (s *procSampler) countWchan(pid, tid int) {
+	if w := procfs.ReadTaskWchan(pid, tid); w != "" {
+		s.states.Wchan[w]++
+	}
+}
+
+// Stop ends sampling, takes a final reading, and returns what was gathered.
+func (s *procSampler) Stop() procStats {
+	if s == nil {
+		return procStats{}
+	}
+	close(s.stop)
+	<-s.done
+	s.poll()
+
+	var delayNS, blkioTicks uint64
+	for _, ns := range s.runDelay {
+		delayNS += ns
+	}
+	for _, t := range s.blkio {
+		blkioTicks += t
+	}
+	states := s.states
+	states.BlkioDelayMS = float64(blkioTicks) * 1000 / procfs.ClockTicks
+	if len(states.Wchan) == 0 {
+		states.Wchan = nil
+	}
+	threads := make([]model.ThreadCPU, 0, len(s.threads))
+	for _, t := range s.threads {
+		threads = append(threads, t)
+	}
+	var mem *model.MemoryComposition
+	if s.memory.PeakKB > 0 || s.memory.SwapKB > 0 {
+		m := s.memory
+		mem = &m
+	}
+	return procStats{
+		Timeline:    s.points,
+		RunDelayMS:  float64(delayNS) / float64(time.Millisecond),
+		WaitStates:  states,
+		Memory:      mem,
+		Threads:     threads,
+		PeakThreads: s.peakThr,
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package runner
+
+import (
+	"time"
+)
+
+// procSampler is a no-op where /proc is not available.
+type procSampler struct{}
+
+func startSampler(pid int, start time.Time, interval time.Duration, timeline, detail bool) *procSampler {
+	return nil
+}
+
+func (s *procSampler) Stop() procStats {
+	return procStats{}
+}
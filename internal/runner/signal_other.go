@@ -0,0 +1,25 @@
+//go:build !linux && !darwin
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+)
+
+// forwardSignals is empty: samples share our console and get its signals
+// directly.
+var forwardSignals []os.Signal
+
+func newProcessGroup(cmd *exec.Cmd) {}
+
+// terminate kills p; there is no polite request to send here.
+func terminate(p *os.Process) error {
+	return p.Kill()
+}
+
+func killGroup(p *os.Process) error {
+	return p.Kill()
+}
+
+func (f *forwarder) handle(sig os.Signal) {}
@@ -0,0 +1,52 @@
+//go:build linux || darwin
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var forwardSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGTSTP, syscall.SIGCONT}
+
+// newProcessGroup makes cmd the leader of its own process group, so the
+// whole tree can be signalled at once and terminal signals reach only us.
+func newProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// terminate asks p's process group to exit.
+func terminate(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGTERM)
+}
+
+// killGroup kills p's process group.
+func killGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
+
+func (f *forwarder) handle(sig os.Signal) {
+	f.mu.Lock()
+	if f.pgid != 0 {
+		_ = syscall.Kill(-f.pgid, sig.(syscall.Signal))
+		f.sent = append(f.sent, sig.String())
+	}
+	stop := sig != syscall.SIGTSTP && sig != syscall.SIGCONT
+	if stop {
+		f.stop = true
+		f.cut = f.pgid != 0
+	}
+	f.mu.Unlock()
+	if stop {
+		f.cancel()
+	}
+	if sig == syscall.SIGTSTP {
+		// Stop as well, so the shell sees the job suspended. The SIGCONT it
+		// sends on fg reaches only our group and is forwarded in turn.
+		_ = syscall.Kill(os.Getpid(), syscall.SIGSTOP)
+	}
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultKillGrace is how long a timed-out sample gets between SIGTERM and
+// SIGKILL when Options.KillGrace is unset.
+const defaultKillGrace = 5 * time.Second
+
+// sampleTimeout stops a sample that runs past its timeout: SIGTERM to its
+// process group first, then SIGKILL if it is still running after the grace
+// period.
+type sampleTimeout struct {
+	once sync.Once
+	done chan struct{}
+	// mu orders signals against Stop, so none is sent once Stop returned.
+	mu      sync.Mutex
+	stopped bool
+	fired   bool
+}
+
+// startTimeout arms the timeout for p. It returns nil, which Stop accepts,
+// when timeout is zero.
+func startTimeout(p *os.Process, timeout, grace time.Duration) *sampleTimeout {
+	if timeout <= 0 {
+		return nil
+	}
+	if grace <= 0 {
+		grace = defaultKillGrace
+	}
+	t := &sampleTimeout{done: make(chan struct{})}
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-t.done:
+			return
+		case <-timer.C:
+		}
+		if !t.send(p, false) {
+			return
+		}
+		timer.Reset(grace)
+		select {
+		case <-t.done:
+		case <-timer.C:
+			t.send(p, true)
+		}
+	}()
+	return t
+}
+
+// Stop disarms the timeout once the child has exited and reports whether it
+// fired. Call it before the child is reaped so a signal cannot reach a
+// recycled pid.
+func (t *sampleTimeout) Stop() bool {
+	if t == nil {
+		return false
+	}
+	t.once.Do(func() { close(t.done) })
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	return t.fired
+}
+
+// send terminates, or with kill kills, p unless Stop was already called.
+func (t *sampleTimeout) send(p *os.Process, kill bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return false
+	}
+	if kill {
+		_ = killGroup(p)
+	} else {
+		t.fired = true
+		_ = terminate(p)
+	}
+	return true
+}
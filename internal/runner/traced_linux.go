@@ -0,0 +1,107 @@
+//go:build linux
+
+package runner
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/perf"
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
+	"github.com/barthollomew/why-is-this-slow/internal/trace"
+)
+
+// runTraced runs cmd under ptrace. The tracer reaps the direct child itself,
+// so the final /proc readings happen in its exit hook and cmd.Wait is only
+// left to flush stderr.
+func runTraced(ctx context.Context, cmd *exec.Cmd, opts Options, env sampleEnv) (exitResult, error) {
+	var res exitResult
+	var smp *procSampler
+	var timeout *sampleTimeout
+	var counters *perf.Counters
+	defer func() { counters.Close() }()
+	before := childSet()
+	start := time.Now()
+	tr, err := trace.Run(cmd, start, trace.Options{
+		Procs:    opts.TraceProcs,
+		Syscalls: opts.Syscalls,
+		Net:      opts.TraceNet,
+		Files:    opts.TraceFiles,
+		BeforeStart: func() {
+			counters = perf.Open()
+		},
+		OnStart: func(pid int) {
+			smp = startSampler(pid, start, pollInterval(opts), opts.SampleInterval > 0, opts.SampleDetail)
+			timeout = startTimeout(cmd.Process, opts.Timeout, opts.KillGrace)
+			env.signals.attach(pid)
+		},
+		OnExit: func(pid int) {
+			res.elapsed = time.Since(start)
+			res.timedOut = timeout.Stop()
+			res.proc = smp.Stop()
+			res.io = exitedIO(pid)
+		},
+	})
+	if err != nil {
+		if smp != nil {
+			smp.Stop()
+		}
+		timeout.Stop()
+		env.signals.detach()
+		return exitResult{}, err
+	}
+	if res.elapsed == 0 {
+		// Killed without passing through the exit stop.
+		res.elapsed = time.Since(start)
+		res.proc = smp.Stop()
+		res.timedOut = timeout.Stop()
+	}
+
+	policy := stopGroup(ctx, cmd, opts, env, res.timedOut)
+	var leftoverUsage Usage
+	before[cmd.Process.Pid] = true
+	res.leftovers, leftoverUsage = collectLeftovers(ctx, before, policy)
+	reaped, reapedUsage := reapedLeftovers(tr.Reaped, before)
+	res.leftovers = append(reaped, res.leftovers...)
+	leftoverUsage.add(reapedUsage)
+	res.forwarded, res.cut = env.signals.detach()
+
+	res.perf = counters.Read()
+
+	// The child is already reaped, so Wait only reports ECHILD.
+	_ = cmd.Wait()
+
+	res.usage = usageFromRusage(&tr.Rusage)
+	res.usage.add(leftoverUsage)
+	res.exitCode, res.signal = statusExit(tr.Status)
+	res.procTree = tr.Procs
+	res.syscalls = tr.Syscalls
+	res.network = tr.Network
+	res.files = tr.Files
+	return res, nil
+}
+
+// reapedLeftovers turns the children the tracer had to reap into leftover
+// records. Those in before belong to earlier samples and are dropped, as
+// reapExited would have.
+func reapedLeftovers(reaped []trace.Reaped, before map[int]bool) ([]model.LeftoverProcess, Usage) {
+	var procs []model.LeftoverProcess
+	var usage Usage
+	uptime, _ := procfs.Uptime()
+	for _, r := range reaped {
+		if before[r.PID] {
+			continue
+		}
+		procs = append(procs, model.LeftoverProcess{
+			PID:        r.PID,
+			PPID:       r.Stat.PPID,
+			Comm:       r.Stat.Comm,
+			LifetimeMS: lifetimeMS(r.Stat, uptime),
+			State:      "exited",
+		})
+		usage.add(usageFromRusage(&r.Rusage))
+	}
+	return procs, usage
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package runner
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/barthollomew/why-is-this-slow/internal/trace"
+)
+
+func runTraced(ctx context.Context, cmd *exec.Cmd, opts Options, env sampleEnv) (exitResult, error) {
+	return exitResult{}, trace.ErrUnsupported
+}
@@ -0,0 +1,7 @@
+//go:build darwin
+
+package runner
+
+import "syscall"
+
+const ioctlGetTermios = syscall.TIOCGETA
@@ -0,0 +1,7 @@
+//go:build linux
+
+package runner
+
+import "syscall"
+
+const ioctlGetTermios = syscall.TCGETS
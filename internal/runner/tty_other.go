@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !windows
+
+package runner
+
+import "os"
+
+// isTerminal falls back to the character-device check where there is no
+// termios ioctl to ask; /dev/null passes it too.
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,33 @@
+//go:build linux || darwin
+
+package runner
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// isTerminal reports whether f is a terminal. Only a tty answers the termios
+// ioctl; /dev/null is a character device too but does not.
+func isTerminal(f *os.File) bool {
+	var t syscall.Termios
+	return ioctl(f, ioctlGetTermios, unsafe.Pointer(&t)) == nil
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package runner
+
+import (
+	"os"
+	"syscall"
+)
+
+// isTerminal reports whether f is a console. NUL is a character device but
+// has no console mode.
+func isTerminal(f *os.File) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(f.Fd()), &mode) == nil
+}
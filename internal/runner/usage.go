@@ -1,8 +1,28 @@
 package runner
 
 type Usage struct {
-	UserMS     float64
-	SysMS      float64
-	MaxRSS     int64
-	MaxRSSUnit string
+	UserMS      float64
+	SysMS       float64
+	MaxRSS      int64
+	MaxRSSUnit  string
+	MinorFaults int64
+	MajorFaults int64
+	InBlock     int64
+	OutBlock    int64
+	VolCtxSw    int64
+	InvolCtxSw  int64
+}
+
+// add folds in the usage of another reaped process. Max RSS stays a maximum
+// because the processes were not necessarily alive at the same time.
+func (u *Usage) add(o Usage) {
+	u.UserMS += o.UserMS
+	u.SysMS += o.SysMS
+	u.MaxRSS = max(u.MaxRSS, o.MaxRSS)
+	u.MinorFaults += o.MinorFaults
+	u.MajorFaults += o.MajorFaults
+	u.InBlock += o.InBlock
+	u.OutBlock += o.OutBlock
+	u.VolCtxSw += o.VolCtxSw
+	u.InvolCtxSw += o.InvolCtxSw
 }
@@ -68,6 +68,43 @@ func (s *Store) Load(id string) (model.RunResult, model.Analysis, error) {
 	return rec.Run, rec.Analysis, nil
 }
 
+func (s *Store) SweepPath(id string) string {
+	return filepath.Join(s.base, "sweeps", fmt.Sprintf("%s.json", id))
+}
+
+// SaveSweep writes the sweep's manifest. It is rewritten after every run so
+// an aborted sweep still groups the runs it finished.
+func (s *Store) SaveSweep(sw model.Sweep) (string, error) {
+	data, err := json.MarshalIndent(sw, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := s.SweepPath(sw.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *Store) LoadSweep(id string) (model.Sweep, error) {
+	path := s.SweepPath(id)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return model.Sweep{}, fmt.Errorf("sweep id %q not found at %s: %w", id, path, err)
+		}
+		return model.Sweep{}, fmt.Errorf("read sweep %q: %w", id, err)
+	}
+	var sw model.Sweep
+	if err := json.Unmarshal(data, &sw); err != nil {
+		return model.Sweep{}, fmt.Errorf("parse sweep %q: %w", id, err)
+	}
+	return sw, nil
+}
+
 func defaultBase() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
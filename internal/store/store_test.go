@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
 )
 
 func TestLoadMissingRunIncludesID(t *testing.T) {
@@ -27,3 +29,21 @@ func TestLoadMissingRunIncludesID(t *testing.T) {
 		t.Fatalf("expected error to mention path %q, got %q", path, err)
 	}
 }
+
+func TestSweepRoundTrip(t *testing.T) {
+	st := &Store{base: t.TempDir()}
+	sw := model.Sweep{ID: "sweep-1", Command: []string{"sleep", "{n}"}, Params: []model.SweepParam{{Name: "n", Values: []string{"1", "2"}}}, Runs: []string{"a", "b"}}
+	if _, err := st.SaveSweep(sw); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := st.LoadSweep("sweep-1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(got.Runs) != 2 || got.Params[0].Values[1] != "2" {
+		t.Fatalf("unexpected sweep: %+v", got)
+	}
+	if _, err := st.LoadSweep("missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got %v", err)
+	}
+}
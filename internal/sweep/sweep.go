@@ -0,0 +1,172 @@
+// Package sweep expands --param and --env-matrix axes into the combinations
+// a sweep runs, and fills {name} placeholders in the command.
+package sweep
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+// maxValues guards against a range typo expanding to millions of runs.
+const maxValues = 1000
+
+var (
+	nameRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	placeRe = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	rangeRe = regexp.MustCompile(`^range\(\s*(-?\d+)\s*,\s*(-?\d+)\s*(?:,\s*([*+]?)\s*(\d+)\s*)?\)$`)
+)
+
+// Combination is one point of a sweep: a value for every param and every
+// env-matrix variable.
+type Combination struct {
+	Params map[string]string
+	Env    map[string]string
+}
+
+// Parse reads "name=a,b,c" or "name=range(start,end[,step])". The range end
+// is inclusive; a step of "*k" multiplies and "k" or "+k" adds, default 1.
+func Parse(spec string) (model.SweepParam, error) {
+	name, values, ok := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !ok || !nameRe.MatchString(name) {
+		return model.SweepParam{}, fmt.Errorf("%q: expected name=values", spec)
+	}
+	values = strings.TrimSpace(values)
+	p := model.SweepParam{Name: name}
+	if strings.HasPrefix(values, "range(") {
+		vals, err := parseRange(values)
+		if err != nil {
+			return model.SweepParam{}, fmt.Errorf("%q: %w", spec, err)
+		}
+		p.Values = vals
+		return p, nil
+	}
+	for _, v := range strings.Split(values, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			p.Values = append(p.Values, v)
+		}
+	}
+	if len(p.Values) == 0 {
+		return model.SweepParam{}, fmt.Errorf("%q: no values", spec)
+	}
+	return p, nil
+}
+
+func parseRange(s string) ([]string, error) {
+	m := rangeRe.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("expected range(start,end[,step]) with integers")
+	}
+	start, _ := strconv.ParseInt(m[1], 10, 64)
+	end, _ := strconv.ParseInt(m[2], 10, 64)
+	step := int64(1)
+	if m[4] != "" {
+		step, _ = strconv.ParseInt(m[4], 10, 64)
+	}
+	multiply := m[3] == "*"
+	switch {
+	case end < start:
+		return nil, fmt.Errorf("range end %d is below start %d", end, start)
+	case multiply && (step < 2 || start < 1):
+		return nil, fmt.Errorf("a *step needs a factor of at least 2 and a positive start")
+	case !multiply && step < 1:
+		return nil, fmt.Errorf("step must be positive")
+	}
+	var vals []string
+	for v := start; v <= end; {
+		if len(vals) == maxValues {
+			return nil, fmt.Errorf("more than %d values", maxValues)
+		}
+		vals = append(vals, strconv.FormatInt(v, 10))
+		if multiply {
+			v *= step
+		} else {
+			v += step
+		}
+	}
+	return vals, nil
+}
+
+// Expand returns every combination of params and env, varying the last
+// axis fastest.
+func Expand(params, env []model.SweepParam) []Combination {
+	combos := []Combination{{Params: map[string]string{}, Env: map[string]string{}}}
+	axes := append(append([]model.SweepParam(nil), params...), env...)
+	for i, axis := range axes {
+		isEnv := i >= len(params)
+		var next []Combination
+		for _, c := range combos {
+			for _, v := range axis.Values {
+				n := Combination{Params: copyMap(c.Params), Env: copyMap(c.Env)}
+				if isEnv {
+					n.Env[axis.Name] = v
+				} else {
+					n.Params[axis.Name] = v
+				}
+				next = append(next, n)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// Apply substitutes {name} in each arg. Braces that do not name a param,
+// such as find's {} or awk programs, are left alone.
+func Apply(args []string, params map[string]string) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = placeRe.ReplaceAllStringFunc(a, func(m string) string {
+			if v, ok := params[m[1:len(m)-1]]; ok {
+				return v
+			}
+			return m
+		})
+	}
+	return out
+}
+
+// CheckUsed returns an error naming the first param the command never
+// references, which is almost always a typo.
+func CheckUsed(args []string, params []model.SweepParam) error {
+	joined := strings.Join(args, "\x00")
+	for _, p := range params {
+		if !strings.Contains(joined, "{"+p.Name+"}") {
+			return fmt.Errorf("--param %s is not used in the command; reference it as {%s}", p.Name, p.Name)
+		}
+	}
+	return nil
+}
+
+// EnvList turns a combination's env into KEY=VALUE pairs.
+func EnvList(env map[string]string) []string {
+	out := make([]string, 0, len(env))
+	for k, v := range env {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// NewID returns an ID in the style of run IDs, marked as a sweep.
+func NewID() string {
+	buf := make([]byte, 4)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("sweep-%s-%s", time.Now().UTC().Format("20060102T150405Z0700"), hex.EncodeToString(buf))
+}
+
+func copyMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m)+1)
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package sweep
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string][]string{
+		"threads=1,2,4,8":             {"1", "2", "4", "8"},
+		"size=range(1000,100000,*10)": {"1000", "10000", "100000"},
+		"n=range(1,3)":                {"1", "2", "3"},
+		"n=range(0, 10, +5)":          {"0", "5", "10"},
+		"mode=fast, slow":             {"fast", "slow"},
+		"GOMAXPROCS=range(1,8,*2)":    {"1", "2", "4", "8"},
+		"size=range(1000,99999,*10)":  {"1000", "10000"},
+	}
+	for spec, want := range cases {
+		p, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if !reflect.DeepEqual(p.Values, want) {
+			t.Fatalf("%s: got %v, want %v", spec, p.Values, want)
+		}
+	}
+	for _, bad := range []string{"threads", "=1,2", "x=", "x=range(5,1)", "x=range(0,10,*2)", "x=range(1,10,*1)", "x=range(1,1000000)", "x=range(a,b)"} {
+		if _, err := Parse(bad); err == nil {
+			t.Fatalf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestExpandAndApply(t *testing.T) {
+	params := []model.SweepParam{{Name: "a", Values: []string{"1", "2"}}}
+	env := []model.SweepParam{{Name: "GOMAXPROCS", Values: []string{"1", "4"}}}
+	combos := Expand(params, env)
+	if len(combos) != 4 || combos[1].Params["a"] != "1" || combos[1].Env["GOMAXPROCS"] != "4" || combos[2].Params["a"] != "2" {
+		t.Fatalf("unexpected combinations: %+v", combos)
+	}
+	got := Apply([]string{"run", "--a={a}", "{}", "{b}"}, combos[3].Params)
+	if !reflect.DeepEqual(got, []string{"run", "--a=2", "{}", "{b}"}) {
+		t.Fatalf("unexpected args: %v", got)
+	}
+	if err := CheckUsed([]string{"run", "{a}"}, params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := CheckUsed([]string{"run", "{b}"}, params); err == nil {
+		t.Fatalf("expected an unused param error")
+	}
+	if env := EnvList(combos[3].Env); !reflect.DeepEqual(env, []string{"GOMAXPROCS=4"}) {
+		t.Fatalf("unexpected env: %v", env)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Starts a copy of itself that outlives the parent, like a daemon would.
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "child" {
+		time.Sleep(300 * time.Millisecond)
+		return
+	}
+	cmd := exec.Command(os.Args[0], "child")
+	if err := cmd.Start(); err != nil {
+		panic(err)
+	}
+}
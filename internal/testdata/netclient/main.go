@@ -0,0 +1,22 @@
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+)
+
+// Connects to the address in os.Args[1], sends a line and reads the reply.
+func main() {
+	conn, err := net.Dial("tcp", os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		panic(err)
+	}
+	if _, err := io.ReadAll(conn); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	path := filepath.Join(os.TempDir(), "why-is-this-slow-writer")
+	if len(os.Args) > 1 {
+		path = os.Args[1]
+	}
+	buf := make([]byte, 1<<20)
+	if err := os.WriteFile(path, buf, 0o644); err != nil {
+		panic(err)
+	}
+	_ = os.Remove(path)
+}
@@ -0,0 +1,233 @@
+//go:build linux
+
+package trace
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+const (
+	atFDCWD     = -100
+	atEmptyPath = 0x1000
+	maxPathLen  = 4096
+	// maxFilePaths caps the stored path table; the totals still cover all.
+	maxFilePaths = 50000
+)
+
+type fileOp int
+
+const (
+	fileOpen fileOp = iota
+	fileStat
+	fileReaddir
+)
+
+// fileCalls maps syscall names to what they do with a path and where the
+// dirfd and path arguments are; -1 means the call has no such argument.
+var fileCalls = map[string]struct {
+	op    fileOp
+	dirfd int
+	path  int
+}{
+	"open":       {fileOpen, -1, 0},
+	"creat":      {fileOpen, -1, 0},
+	"openat":     {fileOpen, 0, 1},
+	"openat2":    {fileOpen, 0, 1},
+	"stat":       {fileStat, -1, 0},
+	"lstat":      {fileStat, -1, 0},
+	"newfstatat": {fileStat, 0, 1},
+	"statx":      {fileStat, 0, 1},
+	"access":     {fileStat, -1, 0},
+	"faccessat":  {fileStat, 0, 1},
+	"faccessat2": {fileStat, 0, 1},
+	"readlink":   {fileStat, -1, 0},
+	"readlinkat": {fileStat, 0, 1},
+	"getdents":   {fileReaddir, 0, -1},
+	"getdents64": {fileReaddir, 0, -1},
+}
+
+// fileTracker resolves the paths of open/stat/readdir calls and counts calls
+// and time per path.
+type fileTracker struct {
+	paths map[string]*model.FileAccess
+	fds   map[int]map[int]string // tgid -> fd -> path
+	cwd   map[int]string         // tgid -> cwd, read lazily
+	total model.FileTrace
+}
+
+func newFileTracker() *fileTracker {
+	return &fileTracker{
+		paths: map[string]*model.FileAccess{},
+		fds:   map[int]map[int]string{},
+		cwd:   map[int]string{},
+	}
+}
+
+func (f *fileTracker) fork(parent, child int) {
+	if fds := f.fds[parent]; len(fds) > 0 {
+		cp := make(map[int]string, len(fds))
+		for fd, p := range fds {
+			cp[fd] = p
+		}
+		f.fds[child] = cp
+	}
+}
+
+// enter resolves the call's path while its arguments are intact.
+func (f *fileTracker) enter(t *tracee, name string) {
+	t.filePath = ""
+	switch name {
+	case "chdir", "fchdir":
+		delete(f.cwd, t.tgid)
+		return
+	}
+	call, ok := fileCalls[name]
+	if !ok {
+		return
+	}
+	if call.op == fileReaddir {
+		t.filePath = f.fdPath(t.tgid, int(int32(t.args[0])))
+		return
+	}
+	path, ok := peekString(t.tid, uintptr(t.args[call.path]))
+	if !ok {
+		return
+	}
+	dirfd := atFDCWD
+	if call.dirfd >= 0 {
+		dirfd = int(int32(t.args[call.dirfd]))
+	}
+	if path == "" && name == "newfstatat" && t.args[3]&atEmptyPath != 0 {
+		// fstat by another name.
+		t.filePath = f.fdPath(t.tgid, dirfd)
+		return
+	}
+	t.filePath = f.resolve(t.tgid, dirfd, path)
+}
+
+func (f *fileTracker) exit(t *tracee, name string, ret int64, now time.Time) {
+	if name == "close" {
+		delete(f.fds[t.tgid], int(int32(t.args[0])))
+		return
+	}
+	call, ok := fileCalls[name]
+	if !ok || t.filePath == "" {
+		return
+	}
+	path := t.filePath
+	t.filePath = ""
+
+	fa := f.paths[path]
+	if fa == nil {
+		fa = &model.FileAccess{Path: path}
+		f.paths[path] = fa
+	}
+	ms := msSince(t.enter, now)
+	fa.TimeMS += ms
+	failed := ret < 0 && ret >= -4095
+	if failed {
+		fa.Errors++
+		f.total.Errors++
+	}
+	switch call.op {
+	case fileOpen:
+		fa.Opens++
+		f.total.Opens++
+		f.total.OpenMS += ms
+		if !failed {
+			if f.fds[t.tgid] == nil {
+				f.fds[t.tgid] = map[int]string{}
+			}
+			f.fds[t.tgid][int(ret)] = path
+		}
+	case fileStat:
+		fa.Stats++
+		f.total.Stats++
+		f.total.MetaMS += ms
+	case fileReaddir:
+		fa.Readdirs++
+		f.total.Readdirs++
+		f.total.MetaMS += ms
+	}
+}
+
+func (f *fileTracker) resolve(tgid, dirfd int, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	var base string
+	if dirfd == atFDCWD {
+		base = f.cwd[tgid]
+		if base == "" {
+			base, _ = os.Readlink(filepath.Join("/proc", strconv.Itoa(tgid), "cwd"))
+			f.cwd[tgid] = base
+		}
+	} else {
+		base = f.fdPath(tgid, dirfd)
+	}
+	if base == "" {
+		return ""
+	}
+	return filepath.Join(base, path)
+}
+
+// fdPath names an fd from our own open tracking, or from /proc for fds that
+// were opened before tracing or by calls we do not follow.
+func (f *fileTracker) fdPath(tgid, fd int) string {
+	if p, ok := f.fds[tgid][fd]; ok {
+		return p
+	}
+	p, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(tgid), "fd", strconv.Itoa(fd)))
+	if err != nil || !filepath.IsAbs(p) {
+		return ""
+	}
+	return p
+}
+
+func (f *fileTracker) result() *model.FileTrace {
+	out := f.total
+	out.Paths = len(f.paths)
+	out.Top = make([]model.FileAccess, 0, min(len(f.paths), maxFilePaths))
+	for _, fa := range f.paths {
+		out.Top = append(out.Top, *fa)
+	}
+	sort.Slice(out.Top, func(i, j int) bool {
+		if out.Top[i].TimeMS != out.Top[j].TimeMS {
+			return out.Top[i].TimeMS > out.Top[j].TimeMS
+		}
+		return out.Top[i].Path < out.Top[j].Path
+	})
+	if len(out.Top) > maxFilePaths {
+		out.Top = out.Top[:maxFilePaths]
+	}
+	return &out
+}
+
+// peekString copies a NUL-terminated string out of a stopped tracee.
+func peekString(tid int, addr uintptr) (string, bool) {
+	if addr == 0 {
+		return "", false
+	}
+	var out []byte
+	word := make([]byte, 8)
+	for len(out) < maxPathLen {
+		// PEEKDATA reads whole words, so a word never straddles a page the
+		// tracee cannot read unless the string itself does.
+		if _, err := syscall.PtracePeekData(tid, addr+uintptr(len(out)), word); err != nil {
+			return "", false
+		}
+		if i := bytes.IndexByte(word, 0); i >= 0 {
+			return string(append(out, word[:i]...)), true
+		}
+		out = append(out, word...)
+	}
+	return "", false
+}
@@ -0,0 +1,284 @@
+//go:build linux
+
+package trace
+
+import (
+	"encoding/binary"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+)
+
+const (
+	solSocket = 1
+	soError   = 4
+	// errnoInProgress is what a non-blocking connect returns.
+	errnoInProgress = int64(syscall.EINPROGRESS)
+	errnoAgain      = int64(syscall.EAGAIN)
+
+	pollIn = 0x1
+)
+
+var (
+	netReads  = map[string]bool{"read": true, "readv": true, "recvfrom": true, "recvmsg": true, "recvmmsg": true}
+	netWrites = map[string]bool{"write": true, "writev": true, "sendto": true, "sendmsg": true, "sendmmsg": true}
+)
+
+// sock is a socket fd of one traced process.
+type sock struct {
+	proto string
+	dest  *model.NetDest
+	// connectStart is set while a non-blocking connect is in flight.
+	connectStart time.Time
+	// againSince is the entry of the first read that found no data since the
+	// last successful one. With non-blocking sockets the wait happens in
+	// poll/epoll, so blocked time runs from there to the read that succeeds.
+	againSince time.Time
+}
+
+// netTracker follows socket fds through socket/connect/read/write/close and
+// charges connect and receive time to the remote address.
+type netTracker struct {
+	socks map[int]map[int]*sock // tgid -> fd
+	dests map[string]*model.NetDest
+}
+
+func newNetTracker() *netTracker {
+	return &netTracker{
+		socks: map[int]map[int]*sock{},
+		dests: map[string]*model.NetDest{},
+	}
+}
+
+func (n *netTracker) fds(tgid int) map[int]*sock {
+	fds := n.socks[tgid]
+	if fds == nil {
+		fds = map[int]*sock{}
+		n.socks[tgid] = fds
+	}
+	return fds
+}
+
+// fork gives a new process a copy of its parent's socket table.
+func (n *netTracker) fork(parent, child int) {
+	for fd, sk := range n.socks[parent] {
+		cp := *sk
+		n.fds(child)[fd] = &cp
+	}
+}
+
+func (n *netTracker) dest(proto, addr string) *model.NetDest {
+	key := proto + " " + addr
+	d := n.dests[key]
+	if d == nil {
+		d = &model.NetDest{Address: addr, Proto: proto}
+		n.dests[key] = d
+	}
+	return d
+}
+
+// enter runs at syscall entry. connect's address is copied out now, while the
+// call's arguments are known to be intact.
+func (n *netTracker) enter(t *tracee, name string, now time.Time) {
+	switch name {
+	case "connect":
+		t.connectAddr = ""
+		if size := min(int(t.args[2]), 128); size >= 2 {
+			buf := make([]byte, size)
+			if _, err := syscall.PtracePeekData(t.tid, uintptr(t.args[1]), buf); err == nil {
+				t.connectAddr = parseSockaddr(buf)
+			}
+		}
+	case "getsockopt":
+		// Completion of a non-blocking connect is read with SO_ERROR; that is
+		// handled at exit, where the error value is known.
+	case "select", "pselect6":
+		// The kernel overwrites the set with the ready fds, so read it now.
+		t.selectFDs = n.selectWanted(t)
+	default:
+		if netReads[name] || netWrites[name] {
+			if sk := n.socks[t.tgid][int(t.args[0])]; sk != nil && !sk.connectStart.IsZero() {
+				n.connected(sk, now, true)
+			}
+		}
+	}
+}
+
+// exit runs at syscall exit with the call's return value.
+func (n *netTracker) exit(t *tracee, name string, ret int64, now time.Time) {
+	fd := int(t.args[0])
+	switch name {
+	case "socket":
+		domain := t.args[0]
+		if ret >= 0 && (domain == afInet || domain == afInet6 || domain == afUnix) {
+			n.fds(t.tgid)[int(ret)] = &sock{proto: sockProto(domain, t.args[1])}
+		}
+	case "connect":
+		if t.connectAddr == "" {
+			return
+		}
+		sk := n.fds(t.tgid)[fd]
+		if sk == nil {
+			// Created before tracing started, or by a call we do not follow.
+			sk = &sock{proto: "?"}
+			n.fds(t.tgid)[fd] = sk
+		}
+		sk.dest = n.dest(sk.proto, t.connectAddr)
+		switch {
+		case ret == 0:
+			sk.connectStart = t.enter
+			n.connected(sk, now, true)
+		case ret == -errnoInProgress:
+			sk.connectStart = t.enter
+		case ret == -int64(syscall.EALREADY) || ret == -int64(syscall.EISCONN):
+		default:
+			sk.connectStart = t.enter
+			n.connected(sk, now, false)
+		}
+	case "getsockopt":
+		sk := n.socks[t.tgid][fd]
+		if sk == nil || sk.connectStart.IsZero() || t.args[1] != solSocket || t.args[2] != soError || ret != 0 {
+			return
+		}
+		buf := make([]byte, 4)
+		if _, err := syscall.PtracePeekData(t.tid, uintptr(t.args[3]), buf); err != nil {
+			return
+		}
+		n.connected(sk, now, binary.LittleEndian.Uint32(buf) == 0)
+	case "poll", "ppoll":
+		if ret >= 0 {
+			n.pollWaited(t, now)
+		}
+	case "select", "pselect6":
+		if ret >= 0 {
+			for _, fd := range t.selectFDs {
+				n.waited(n.socks[t.tgid][fd], t.enter, now)
+			}
+		}
+		t.selectFDs = nil
+	case "close":
+		delete(n.socks[t.tgid], fd)
+	case "dup2", "dup3":
+		if sk := n.socks[t.tgid][fd]; sk != nil && ret >= 0 {
+			cp := *sk
+			n.fds(t.tgid)[int(ret)] = &cp
+		}
+	default:
+		sk := n.socks[t.tgid][fd]
+		if sk == nil || sk.dest == nil {
+			return
+		}
+		if netWrites[name] {
+			if ret > 0 {
+				sk.dest.BytesOut += ret
+			}
+			return
+		}
+		if !netReads[name] {
+			return
+		}
+		if ret == -errnoAgain {
+			if sk.againSince.IsZero() {
+				sk.againSince = t.enter
+			}
+			return
+		}
+		since := t.enter
+		if !sk.againSince.IsZero() {
+			since = sk.againSince
+			sk.againSince = time.Time{}
+		}
+		sk.dest.Reads++
+		sk.dest.RecvBlockedMS += msSince(since, now)
+		if ret > 0 {
+			sk.dest.BytesIn += ret
+		}
+	}
+}
+
+// pollWaited charges a poll to the sockets it waited to read from, whether it
+// returned an event or timed out. Blocking in poll and then reading is how
+// most C clients wait for a reply. When several sockets are polled at once
+// each is charged the full time.
+func (n *netTracker) pollWaited(t *tracee, now time.Time) {
+	fds := n.socks[t.tgid]
+	count := int(t.args[1])
+	if len(fds) == 0 || count <= 0 || count > 1024 {
+		return
+	}
+	buf := make([]byte, count*8) // struct pollfd {int fd; short events, revents;}
+	if _, err := syscall.PtracePeekData(t.tid, uintptr(t.args[0]), buf); err != nil {
+		return
+	}
+	for i := 0; i < count; i++ {
+		fd := int(int32(binary.LittleEndian.Uint32(buf[i*8:])))
+		events := binary.LittleEndian.Uint16(buf[i*8+4:])
+		if events&pollIn != 0 {
+			n.waited(fds[fd], t.enter, now)
+		}
+	}
+}
+
+// selectWanted returns the tracked sockets in select's read fd_set.
+func (n *netTracker) selectWanted(t *tracee) []int {
+	fds := n.socks[t.tgid]
+	nfds := int(t.args[0])
+	if len(fds) == 0 || t.args[1] == 0 || nfds <= 0 || nfds > 1024 {
+		return nil
+	}
+	buf := make([]byte, (nfds+63)/64*8)
+	if _, err := syscall.PtracePeekData(t.tid, uintptr(t.args[1]), buf); err != nil {
+		return nil
+	}
+	var out []int
+	for fd := range fds {
+		if fd < nfds && buf[fd/8]&(1<<(fd%8)) != 0 {
+			out = append(out, fd)
+		}
+	}
+	return out
+}
+
+// waited adds time spent waiting for a socket to become readable. The read
+// that follows then only adds its own duration.
+func (n *netTracker) waited(sk *sock, since, now time.Time) {
+	if sk == nil || sk.dest == nil {
+		return
+	}
+	sk.dest.RecvBlockedMS += msSince(since, now)
+	sk.againSince = time.Time{}
+}
+
+func (n *netTracker) connected(sk *sock, now time.Time, ok bool) {
+	if sk.dest == nil {
+		sk.connectStart = time.Time{}
+		return
+	}
+	ms := msSince(sk.connectStart, now)
+	sk.connectStart = time.Time{}
+	sk.dest.ConnectMS += ms
+	sk.dest.MaxConnectMS = max(sk.dest.MaxConnectMS, ms)
+	if ok {
+		sk.dest.Connects++
+	} else {
+		sk.dest.ConnectErrors++
+	}
+}
+
+// result lists destinations by the time charged to them, slowest first.
+func (n *netTracker) result() []model.NetDest {
+	out := make([]model.NetDest, 0, len(n.dests))
+	for _, d := range n.dests {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ti, tj := out[i].ConnectMS+out[i].RecvBlockedMS, out[j].ConnectMS+out[j].RecvBlockedMS
+		if ti != tj {
+			return ti > tj
+		}
+		return out[i].Address < out[j].Address
+	})
+	return out
+}
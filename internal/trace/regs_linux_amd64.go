@@ -0,0 +1,19 @@
+//go:build linux && amd64
+
+package trace
+
+import "syscall"
+
+const canTraceSyscalls = true
+
+func syscallNumber(regs *syscall.PtraceRegs) int {
+	return int(regs.Orig_rax)
+}
+
+func syscallReturn(regs *syscall.PtraceRegs) int64 {
+	return int64(regs.Rax)
+}
+
+func syscallArgs(regs *syscall.PtraceRegs) [6]uint64 {
+	return [6]uint64{regs.Rdi, regs.Rsi, regs.Rdx, regs.R10, regs.R8, regs.R9}
+}
@@ -0,0 +1,19 @@
+//go:build linux && arm64
+
+package trace
+
+import "syscall"
+
+const canTraceSyscalls = true
+
+func syscallNumber(regs *syscall.PtraceRegs) int {
+	return int(regs.Regs[8])
+}
+
+func syscallReturn(regs *syscall.PtraceRegs) int64 {
+	return int64(regs.Regs[0])
+}
+
+func syscallArgs(regs *syscall.PtraceRegs) [6]uint64 {
+	return [6]uint64{regs.Regs[0], regs.Regs[1], regs.Regs[2], regs.Regs[3], regs.Regs[4], regs.Regs[5]}
+}
@@ -0,0 +1,23 @@
+//go:build linux && !amd64 && !arm64
+
+package trace
+
+import "syscall"
+
+// Syscall tracing needs a register layout and a syscall table; only amd64 and
+// arm64 have them.
+const canTraceSyscalls = false
+
+var syscallNames = map[int]string{}
+
+func syscallNumber(regs *syscall.PtraceRegs) int {
+	return -1
+}
+
+func syscallReturn(regs *syscall.PtraceRegs) int64 {
+	return 0
+}
+
+func syscallArgs(regs *syscall.PtraceRegs) [6]uint64 {
+	return [6]uint64{}
+}
@@ -0,0 +1,73 @@
+package trace
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strconv"
+)
+
+const (
+	afUnix  = 1
+	afInet  = 2
+	afInet6 = 10
+)
+
+// parseSockaddr formats a raw struct sockaddr as copied out of a tracee.
+// Ports are big-endian; the family is in host order (little-endian on every
+// architecture the tracer supports). It returns "" for families it does not
+// know.
+func parseSockaddr(raw []byte) string {
+	if len(raw) < 2 {
+		return ""
+	}
+	switch binary.LittleEndian.Uint16(raw) {
+	case afInet:
+		if len(raw) < 8 {
+			return ""
+		}
+		port := binary.BigEndian.Uint16(raw[2:])
+		addr := netip.AddrFrom4([4]byte(raw[4:8]))
+		return netip.AddrPortFrom(addr, port).String()
+	case afInet6:
+		if len(raw) < 24 {
+			return ""
+		}
+		port := binary.BigEndian.Uint16(raw[2:])
+		addr := netip.AddrFrom16([16]byte(raw[8:24])).Unmap()
+		return netip.AddrPortFrom(addr, port).String()
+	case afUnix:
+		path := raw[2:]
+		if len(path) == 0 {
+			return "unix:"
+		}
+		if path[0] == 0 {
+			// Abstract namespace.
+			return "unix:@" + trimNUL(path[1:])
+		}
+		return "unix:" + trimNUL(path)
+	}
+	return ""
+}
+
+func trimNUL(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// sockProto names a socket() type argument.
+func sockProto(domain, typ uint64) string {
+	if domain == afUnix {
+		return "unix"
+	}
+	switch typ & 0xf {
+	case 1:
+		return "tcp"
+	case 2:
+		return "udp"
+	}
+	return "sock" + strconv.FormatUint(typ&0xf, 10)
+}
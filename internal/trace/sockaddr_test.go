@@ -0,0 +1,22 @@
+package trace
+
+import "testing"
+
+func TestParseSockaddr(t *testing.T) {
+	cases := []struct {
+		raw  []byte
+		want string
+	}{
+		{[]byte{2, 0, 0x1f, 0x90, 127, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}, "127.0.0.1:8080"},
+		{append([]byte{10, 0, 0x01, 0xbb, 0, 0, 0, 0}, append(make([]byte, 15), 1, 0, 0, 0, 0)...), "[::1]:443"},
+		{append([]byte{10, 0, 0, 53, 0, 0, 0, 0}, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 10, 0, 0, 1), "10.0.0.1:53"},
+		{append([]byte{1, 0}, "/run/pg.sock\x00junk"...), "unix:/run/pg.sock"},
+		{append([]byte{1, 0, 0}, "bus"...), "unix:@bus"},
+		{[]byte{17, 0, 1, 2}, ""},
+	}
+	for _, c := range cases {
+		if got := parseSockaddr(c.raw); got != c.want {
+			t.Errorf("parseSockaddr(%v) = %q, want %q", c.raw, got, c.want)
+		}
+	}
+}
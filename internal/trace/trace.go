@@ -0,0 +1,66 @@
+// Package trace follows a command's whole process tree with ptrace. It is
+// opt-in because ptrace slows the traced processes down and only works on
+// Linux.
+package trace
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
+)
+
+var (
+	// ErrUnsupported is returned where ptrace tracing is not available.
+	ErrUnsupported = errors.New("process tracing is only supported on Linux")
+	// ErrSyscallsUnsupported is returned on Linux architectures without a
+	// syscall table.
+	ErrSyscallsUnsupported = errors.New("syscall tracing is only supported on linux/amd64 and linux/arm64")
+)
+
+// Options selects what the tracer records.
+type Options struct {
+	// Procs records fork/exec/exit events as a process tree.
+	Procs bool
+	// Syscalls stops every traced thread at syscall entry and exit to count
+	// and time the calls. It is much slower than Procs alone.
+	Syscalls bool
+	// Net follows socket fds to time connects and receives per remote
+	// address. It uses the same syscall stops as Syscalls.
+	Net bool
+	// Files resolves the paths of open/stat/readdir calls and counts calls
+	// and time per path. It uses the same syscall stops as Syscalls.
+	Files bool
+	// BeforeStart runs on the tracing thread just before it forks the child,
+	// for state the child should inherit from that thread.
+	BeforeStart func()
+	// OnStart runs once the direct child has been started.
+	OnStart func(pid int)
+	// OnExit runs while the direct child is stopped on its way out, so its
+	// /proc entries can still be read.
+	OnExit func(pid int)
+}
+
+// Result is what a traced run produced. Status and Rusage describe the direct
+// child, which the tracer reaps itself.
+type Result struct {
+	Status   syscall.WaitStatus
+	Rusage   syscall.Rusage
+	Procs    []model.ProcNode
+	Syscalls []model.SyscallStat
+	Network  []model.NetDest
+	Files    *model.FileTrace
+	// Reaped lists children of the caller that were never traced but exited
+	// while the trace ran, such as leftovers re-parented to a subreaper. The
+	// tracer has to reap them to keep waiting, so it hands them back here.
+	Reaped []Reaped
+}
+
+// Reaped is an untraced child the tracer reaped, with its /proc stat read
+// just before.
+type Reaped struct {
+	PID    int
+	Stat   procfs.Stat
+	Rusage syscall.Rusage
+}
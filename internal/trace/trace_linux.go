@@ -0,0 +1,553 @@
+//go:build linux
+
+package trace
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/barthollomew/why-is-this-slow/internal/model"
+	"github.com/barthollomew/why-is-this-slow/internal/procfs"
+)
+
+const (
+	ptraceOExitKill  = 0x100000
+	ptraceGetSiginfo = 0x4202
+	// syscallTrap is the stop signal of syscall stops with TRACESYSGOOD.
+	syscallTrap = syscall.SIGTRAP | 0x80
+
+	traceOptions = syscall.PTRACE_O_TRACEFORK |
+		syscall.PTRACE_O_TRACEVFORK |
+		syscall.PTRACE_O_TRACECLONE |
+		syscall.PTRACE_O_TRACEEXEC |
+		syscall.PTRACE_O_TRACEEXIT |
+		ptraceOExitKill
+)
+
+// pollCalls return 0 when their timeout expired without an event.
+var pollCalls = map[string]bool{
+	"poll":         true,
+	"ppoll":        true,
+	"select":       true,
+	"pselect6":     true,
+	"epoll_wait":   true,
+	"epoll_pwait":  true,
+	"epoll_pwait2": true,
+}
+
+// tracee is one traced thread.
+type tracee struct {
+	tid      int
+	tgid     int
+	attached bool // initial stop seen
+	stopSent bool // drain sent a SIGSTOP that has not been seen yet
+
+	// Syscall in progress, between its entry and exit stops.
+	inSyscall   bool
+	nr          int
+	args        [6]uint64
+	enter       time.Time
+	connectAddr string
+	selectFDs   []int
+	filePath    string
+}
+
+type session struct {
+	opts     Options
+	start    time.Time
+	main     int
+	tracees  map[int]*tracee
+	nodes    map[int]*model.ProcNode
+	order    []int
+	parents  map[int]bool // processes that forked a traced child
+	detached map[int]bool
+	syscalls map[int]*model.SyscallStat
+	net      *netTracker
+	files    *fileTracker
+	mainDone bool
+	draining bool
+	result   Result
+}
+
+// Run starts cmd under ptrace and follows every process and thread it creates
+// until the direct child has exited. Descendants still running at that point
+// are detached and left to the caller's leftover handling. start is the
+// reference for node timestamps.
+func Run(cmd *exec.Cmd, start time.Time, opts Options) (Result, error) {
+	if (opts.Syscalls || opts.Net || opts.Files) && !canTraceSyscalls {
+		return Result{}, ErrSyscallsUnsupported
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Ptrace = true
+
+	type outcome struct {
+		res Result
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		// ptrace requests are only accepted from the tracing thread, which is
+		// the one that forked the child. The thread is never unlocked, so the
+		// runtime discards it afterwards instead of reusing a tracer.
+		runtime.LockOSThread()
+		if opts.BeforeStart != nil {
+			opts.BeforeStart()
+		}
+		if err := cmd.Start(); err != nil {
+			done <- outcome{err: err}
+			return
+		}
+		s := &session{
+			opts:     opts,
+			start:    start,
+			main:     cmd.Process.Pid,
+			tracees:  map[int]*tracee{},
+			nodes:    map[int]*model.ProcNode{},
+			parents:  map[int]bool{},
+			detached: map[int]bool{},
+			syscalls: map[int]*model.SyscallStat{},
+		}
+		if opts.Net {
+			s.net = newNetTracker()
+		}
+		if opts.Files {
+			s.files = newFileTracker()
+		}
+		if opts.OnStart != nil {
+			opts.OnStart(s.main)
+		}
+		s.tracees[s.main] = &tracee{tid: s.main, tgid: s.main}
+		s.addNode(s.main, 0, cmd.Args, cmd.Path, start)
+		res, err := s.loop()
+		done <- outcome{res: res, err: err}
+	}()
+	out := <-done
+	return out.res, out.err
+}
+
+func (s *session) loop() (Result, error) {
+	for {
+		pid, code, err := peekChild()
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			if err == syscall.ECHILD && s.mainDone {
+				break
+			}
+			return s.finish(), err
+		}
+		t, known := s.tracees[pid]
+		if !known && code != cldTrapped {
+			// Not a tracee stop: an untraced child of ours exited.
+			s.reapUntraced(pid)
+			continue
+		}
+
+		var ws syscall.WaitStatus
+		var ru syscall.Rusage
+		if _, err := syscall.Wait4(pid, &ws, syscall.WALL, &ru); err != nil {
+			continue
+		}
+		now := time.Now()
+
+		switch {
+		case ws.Exited() || ws.Signaled():
+			if known {
+				s.exited(t, ws, &ru, now)
+			}
+		case ws.Stopped():
+			if !known && s.detached[pid] {
+				continue
+			}
+			if !known {
+				// A new tracee's first stop can arrive before its parent's
+				// fork event.
+				t = s.track(pid, 0, now)
+			}
+			s.stopped(t, ws, now)
+		}
+
+		if s.mainDone && len(s.tracees) == 0 {
+			break
+		}
+	}
+	return s.finish(), nil
+}
+
+const (
+	pAll       = 0 // P_ALL idtype for waitid
+	cldTrapped = 4 // CLD_TRAPPED si_code: a ptrace stop
+)
+
+// siginfoPID is the offset of si_pid in siginfo_t: three ints, then a union
+// aligned like a pointer.
+var siginfoPID = map[uintptr]int{4: 12, 8: 16}[unsafe.Sizeof(uintptr(0))]
+
+// peekChild waits for the next child event without consuming it and returns
+// the pid and si_code. The caller's other children share our wait queue, so
+// the loop has to look before it reaps.
+func peekChild() (int, int, error) {
+	var info [128]byte // siginfo_t
+	_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pAll, 0, uintptr(unsafe.Pointer(&info[0])), syscall.WEXITED|syscall.WALL|syscall.WNOWAIT, 0, 0)
+	if errno != 0 {
+		return 0, 0, errno
+	}
+	code := *(*int32)(unsafe.Pointer(&info[8]))
+	pid := *(*int32)(unsafe.Pointer(&info[siginfoPID]))
+	return int(pid), int(code), nil
+}
+
+// reapUntraced reaps an exited child that was never traced and keeps its
+// stat and rusage for the caller's leftover accounting.
+func (s *session) reapUntraced(pid int) {
+	st, err := procfs.ReadStat(pid)
+	var ws syscall.WaitStatus
+	var ru syscall.Rusage
+	if wpid, werr := syscall.Wait4(pid, &ws, syscall.WALL, &ru); werr != nil || wpid != pid {
+		return
+	}
+	if err != nil || st.PPID != os.Getpid() {
+		// A tracee that died before its first stop, which is not ours to report.
+		return
+	}
+	s.result.Reaped = append(s.result.Reaped, Reaped{PID: pid, Stat: st, Rusage: ru})
+}
+
+func (s *session) stopped(t *tracee, ws syscall.WaitStatus, now time.Time) {
+	sig := ws.StopSignal()
+	if s.draining {
+		s.drainStop(t, ws, now)
+		return
+	}
+	if sig == syscallTrap {
+		s.syscallStop(t, now)
+		s.resume(t, 0)
+		return
+	}
+	if cause := ws.TrapCause(); cause > 0 {
+		s.event(t, cause, now)
+		s.resume(t, 0)
+		return
+	}
+	if !t.attached {
+		// The main child first stops with SIGTRAP after PTRACE_TRACEME + exec;
+		// auto-attached tracees first stop with SIGSTOP.
+		if t.tid == s.main {
+			options := traceOptions
+			if s.syscallStops() {
+				options |= syscall.PTRACE_O_TRACESYSGOOD
+			}
+			_ = syscall.PtraceSetOptions(t.tid, options)
+		}
+		t.attached = true
+		s.resume(t, 0)
+		return
+	}
+	if isGroupStop(t.tid) {
+		// Without PTRACE_SEIZE a group-stop cannot be kept; resume instead.
+		s.resume(t, 0)
+		return
+	}
+	s.resume(t, int(sig))
+}
+
+func (s *session) event(t *tracee, cause int, now time.Time) {
+	switch cause {
+	case syscall.PTRACE_EVENT_FORK, syscall.PTRACE_EVENT_VFORK, syscall.PTRACE_EVENT_CLONE:
+		msg, err := syscall.PtraceGetEventMsg(t.tid)
+		if err != nil {
+			return
+		}
+		if _, ok := s.tracees[int(msg)]; !ok && !s.detached[int(msg)] {
+			s.track(int(msg), t.tgid, now)
+		}
+	case syscall.PTRACE_EVENT_EXEC:
+		// A non-leader thread that execs takes over the leader's pid, along
+		// with its pending execve.
+		if msg, err := syscall.PtraceGetEventMsg(t.tid); err == nil && int(msg) != t.tid {
+			if former := s.tracees[int(msg)]; former != nil {
+				t.inSyscall, t.nr, t.args, t.enter = former.inSyscall, former.nr, former.args, former.enter
+			}
+			delete(s.tracees, int(msg))
+		}
+		if n := s.nodes[t.tgid]; n != nil {
+			if argv := procfs.ReadCmdline(t.tgid); len(argv) > 0 {
+				n.Argv = argv
+			}
+			if exe := procfs.ReadExe(t.tgid); exe != "" {
+				n.Exe = filepath.Base(exe)
+			}
+		}
+	case syscall.PTRACE_EVENT_EXIT:
+		if t.tid == t.tgid {
+			s.snapshotExit(t.tgid, now)
+		}
+		if t.tid == s.main && s.opts.OnExit != nil {
+			s.opts.OnExit(t.tid)
+		}
+	}
+}
+
+// track registers a new tracee. Threads share their process's node; a new
+// process starts as a copy of its parent until it execs.
+func (s *session) track(tid, parent int, now time.Time) *tracee {
+	status, err := procfs.ReadStatus(tid)
+	tgid := tid
+	if err == nil && status.Tgid != 0 {
+		tgid = status.Tgid
+		if parent == 0 {
+			parent = status.PPID
+		}
+	}
+	t := &tracee{tid: tid, tgid: tgid}
+	s.tracees[tid] = t
+	if tgid == tid {
+		argv, exe := []string(nil), ""
+		if p := s.nodes[parent]; p != nil {
+			argv, exe = p.Argv, p.Exe
+		}
+		s.addNode(tid, parent, argv, exe, now)
+		s.parents[parent] = true
+		if s.net != nil {
+			s.net.fork(parent, tid)
+		}
+		if s.files != nil {
+			s.files.fork(parent, tid)
+		}
+	}
+	return t
+}
+
+func (s *session) addNode(pid, ppid int, argv []string, exe string, now time.Time) {
+	s.nodes[pid] = &model.ProcNode{
+		PID:     pid,
+		PPID:    ppid,
+		Exe:     filepath.Base(exe),
+		Argv:    append([]string(nil), argv...),
+		StartMS: msSince(s.start, now),
+	}
+	s.order = append(s.order, pid)
+}
+
+// snapshotExit reads CPU time and peak RSS while the process is stopped at
+// PTRACE_EVENT_EXIT; the stat of a thread group leader covers all threads.
+func (s *session) snapshotExit(pid int, now time.Time) {
+	n := s.nodes[pid]
+	if n == nil {
+		return
+	}
+	n.EndMS = msSince(s.start, now)
+	if st, err := procfs.ReadStat(pid); err == nil {
+		n.UserMS = float64(st.UTime) * 1000 / procfs.ClockTicks
+		n.SysMS = float64(st.STime) * 1000 / procfs.ClockTicks
+	}
+	if status, err := procfs.ReadStatus(pid); err == nil {
+		n.MaxRSSKB = status.VmHWMKB
+	}
+}
+
+func (s *session) exited(t *tracee, ws syscall.WaitStatus, ru *syscall.Rusage, now time.Time) {
+	delete(s.tracees, t.tid)
+	if n := s.nodes[t.tid]; n != nil {
+		if n.EndMS == 0 {
+			n.EndMS = msSince(s.start, now)
+		}
+		if !s.parents[t.tid] {
+			// The exit notification carries rusage with microsecond precision
+			// instead of whole ticks. It also includes reaped children, so it
+			// is only used for processes that never forked.
+			n.UserMS = timevalMS(ru.Utime)
+			n.SysMS = timevalMS(ru.Stime)
+		}
+		if ws.Signaled() {
+			n.Signal = ws.Signal().String()
+			n.ExitCode = 128 + int(ws.Signal())
+		} else {
+			n.ExitCode = ws.ExitStatus()
+		}
+	}
+	if t.tid == s.main {
+		s.mainDone = true
+		s.result.Status = ws
+		s.result.Rusage = *ru
+		s.drain()
+	}
+}
+
+// drain detaches whatever is still traced once the direct child is gone.
+// PTRACE_DETACH needs a stopped tracee, so each running one is sent a SIGSTOP
+// and let go at the stop it causes. Tracees already in a ptrace-stop are let
+// go at that stop instead, so no stray SIGSTOP is left pending for them.
+func (s *session) drain() {
+	s.draining = true
+	for _, t := range s.tracees {
+		if st, err := procfs.ReadTaskStat(t.tgid, t.tid); err == nil && st.State == 't' {
+			continue
+		}
+		if syscall.Tgkill(t.tgid, t.tid, syscall.SIGSTOP) == nil {
+			t.stopSent = true
+		}
+	}
+}
+
+func (s *session) drainStop(t *tracee, ws syscall.WaitStatus, now time.Time) {
+	sig := ws.StopSignal()
+	if sig == syscallTrap {
+		s.syscallStop(t, now)
+		sig = 0
+	}
+	if ws.TrapCause() > 0 {
+		s.event(t, ws.TrapCause(), now)
+		sig = 0
+	}
+	if sig == syscall.SIGSTOP {
+		// Ours, or the initial stop of a tracee created during the drain.
+		s.detach(t, 0)
+		return
+	}
+	if sig != 0 && isGroupStop(t.tid) {
+		sig = 0
+	}
+	if t.stopSent {
+		// Our SIGSTOP is still pending; detach when it arrives.
+		s.resume(t, int(sig))
+		return
+	}
+	s.detach(t, int(sig))
+}
+
+func (s *session) syscallStops() bool {
+	return s.opts.Syscalls || s.opts.Net || s.opts.Files
+}
+
+func (s *session) resume(t *tracee, sig int) {
+	if s.syscallStops() {
+		_ = syscall.PtraceSyscall(t.tid, sig)
+		return
+	}
+	_ = syscall.PtraceCont(t.tid, sig)
+}
+
+func (s *session) detach(t *tracee, sig int) {
+	_, _, _ = syscall.Syscall6(syscall.SYS_PTRACE, syscall.PTRACE_DETACH, uintptr(t.tid), 0, uintptr(sig), 0, 0)
+	delete(s.tracees, t.tid)
+	s.detached[t.tid] = true
+	if n := s.nodes[t.tid]; n != nil && n.EndMS == 0 {
+		// Times cover the traced part of its life only.
+		s.snapshotExit(t.tid, time.Now())
+		n.Detached = true
+	}
+}
+
+func (s *session) finish() Result {
+	res := s.result
+	if s.opts.Procs {
+		res.Procs = make([]model.ProcNode, 0, len(s.order))
+		for _, pid := range s.order {
+			res.Procs = append(res.Procs, *s.nodes[pid])
+		}
+	}
+	for _, st := range s.syscalls {
+		res.Syscalls = append(res.Syscalls, *st)
+	}
+	sort.Slice(res.Syscalls, func(i, j int) bool {
+		if res.Syscalls[i].TotalMS != res.Syscalls[j].TotalMS {
+			return res.Syscalls[i].TotalMS > res.Syscalls[j].TotalMS
+		}
+		return res.Syscalls[i].Count > res.Syscalls[j].Count
+	})
+	if s.net != nil {
+		res.Network = s.net.result()
+	}
+	if s.files != nil {
+		res.Files = s.files.result()
+	}
+	return res
+}
+
+// isGroupStop tells a group-stop from a signal-delivery-stop: only the latter
+// has siginfo.
+func isGroupStop(tid int) bool {
+	var info [128]byte
+	_, _, errno := syscall.Syscall6(syscall.SYS_PTRACE, ptraceGetSiginfo, uintptr(tid), 0, uintptr(unsafe.Pointer(&info[0])), 0, 0)
+	return errno == syscall.EINVAL
+}
+
+// syscallStop handles a syscall-entry or syscall-exit stop. The two look the
+// same, so each thread tracks which one comes next. Calls are counted at
+// entry, since exit and execve of a different thread never return.
+func (s *session) syscallStop(t *tracee, now time.Time) {
+	var regs syscall.PtraceRegs
+	if err := syscall.PtraceGetRegs(t.tid, &regs); err != nil {
+		return
+	}
+	if !t.inSyscall {
+		t.inSyscall = true
+		t.nr = syscallNumber(&regs)
+		t.args = syscallArgs(&regs)
+		t.enter = now
+		if s.opts.Syscalls {
+			s.syscallStat(t.nr).Count++
+		}
+		if s.net != nil {
+			s.net.enter(t, syscallNames[t.nr], now)
+		}
+		if s.files != nil {
+			s.files.enter(t, syscallNames[t.nr])
+		}
+		return
+	}
+	t.inSyscall = false
+	ret := syscallReturn(&regs)
+	if s.net != nil {
+		s.net.exit(t, syscallNames[t.nr], ret, now)
+	}
+	if s.files != nil {
+		s.files.exit(t, syscallNames[t.nr], ret, now)
+	}
+	if !s.opts.Syscalls {
+		return
+	}
+	st := s.syscallStat(t.nr)
+	ms := msSince(t.enter, now)
+	st.TotalMS += ms
+	st.MaxMS = max(st.MaxMS, ms)
+	switch {
+	case ret < 0 && ret >= -4095:
+		st.Errors++
+	case ret == 0 && pollCalls[st.Name]:
+		st.Timeouts++
+		st.TimeoutMS += ms
+	}
+}
+
+func (s *session) syscallStat(nr int) *model.SyscallStat {
+	st := s.syscalls[nr]
+	if st == nil {
+		name, ok := syscallNames[nr]
+		if !ok {
+			name = "syscall_" + strconv.Itoa(nr)
+		}
+		st = &model.SyscallStat{Name: name}
+		s.syscalls[nr] = st
+	}
+	return st
+}
+
+func timevalMS(tv syscall.Timeval) float64 {
+	return float64(tv.Sec)*1000 + float64(tv.Usec)/1000
+}
+
+func msSince(start, t time.Time) float64 {
+	return float64(t.Sub(start)) / float64(time.Millisecond)
+}
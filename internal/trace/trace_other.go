@@ -0,0 +1,13 @@
+//go:build !linux
+
+package trace
+
+import (
+	"os/exec"
+	"time"
+)
+
+// Run is not available on this platform.
+func Run(cmd *exec.Cmd, start time.Time, opts Options) (Result, error) {
+	return Result{}, ErrUnsupported
+}